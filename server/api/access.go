@@ -0,0 +1,22 @@
+package api
+
+import "context"
+
+// Access describes if the server process may read, write or execute
+// (search, for directories) a file or directory
+type Access struct {
+	Readable   bool `json:"readable"`
+	Writable   bool `json:"writable"`
+	Executable bool `json:"executable"`
+}
+
+// statAccess returns the access of the server process to the scoped
+// path, or nil if the root is not an http.Dir or the platform does not
+// support access checks
+func statAccess(ctx context.Context, scoped string) *Access {
+	name, _, ok := hostPath(ctx, scoped)
+	if !ok {
+		return nil
+	}
+	return fileAccess(name)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package api
+
+// fileAccess is not supported on this platform
+func fileAccess(name string) *Access {
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build unix
+
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatsEndpoint_access(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("access is not checked for root")
+	}
+
+	root := t.TempDir()
+	tests := []struct {
+		name                           string
+		mode                           os.FileMode
+		readable, writable, executable bool
+	}{
+		{"none", 0000, false, false, false},
+		{"read-only", 0444, true, false, false},
+		{"read-write", 0644, true, true, false},
+		{"executable", 0755, true, true, true},
+	}
+	for _, test := range tests {
+		name := filepath.Join(root, test.name)
+		if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.Chmod(name, test.mode); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// listed, as files without read access cannot be opened for stats
+	list := decodeBody(t, serveAPI(http.Dir(root), "/api/list/"))
+	for _, test := range tests {
+		body := entryNamed(t, entriesOf(t, list), test.name)
+		if want, have := test.readable, body["readable"]; want != have {
+			t.Errorf("%s: expected readable %#v, got %#v", test.name, want, have)
+		}
+		if want, have := test.writable, body["writable"]; want != have {
+			t.Errorf("%s: expected writable %#v, got %#v", test.name, want, have)
+		}
+		if want, have := test.executable, body["executable"]; want != have {
+			t.Errorf("%s: expected executable %#v, got %#v", test.name, want, have)
+		}
+	}
+
+	// directories are executable if searchable
+	body := decodeBody(t, serveAPI(http.Dir(root), "/api/stats/"))
+	if want, have := true, body["executable"]; want != have {
+		t.Errorf("expected directory executable %#v, got %#v", want, have)
+	}
+}
+
+func TestStatsEndpoint_accessUnknown(t *testing.T) {
+
+	// not known for file systems other than http.Dir
+	body := decodeBody(t, serveAPI(http.FS(os.DirFS(testRoot)), "/api/stats/hello.txt"))
+	if _, ok := body["readable"]; ok {
+		t.Errorf("expected no readable, got %#v", body["readable"])
+	}
+}
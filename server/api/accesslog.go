@@ -0,0 +1,53 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestIDHeader is the header of the request ID, forwarded by the
+// client or a proxy, and echoed in the response
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds forwarded request IDs kept as is
+const maxRequestIDLength = 128
+
+// requestID returns the forwarded ID of the request, or generates one
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" && len(id) <= maxRequestIDLength {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// logAccess echoes the request ID in the response, and returns the
+// response writer to use and a func to log the access when done
+func (cfg *Config) logAccess(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	start := time.Now()
+	id := requestID(r)
+	w.Header().Set(requestIDHeader, id)
+	rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+
+	// path as requested, before the base is stripped
+	path := r.URL.Path
+	if r.RequestURI != "" {
+		path, _, _ = strings.Cut(r.RequestURI, "?")
+	}
+
+	return rec, func() {
+		cfg.logger().LogAttrs(r.Context(), slog.LevelInfo, "access",
+			slog.String("method", r.Method),
+			slog.String("path", path),
+			slog.Int("status", rec.code),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("requestID", id),
+		)
+	}
+}
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+// errArchiveBufferFull is the error of an archive larger than
+// the buffer configured
+var errArchiveBufferFull = errors.New("archive larger than buffer")
+
+// limitedWriter writes up to n bytes, failing with
+// errArchiveBufferFull past them
+type limitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.n {
+		return 0, errArchiveBufferFull
+	}
+	l.n -= int64(len(p))
+	return l.w.Write(p)
+}
+
+// archiveWriter writes entries of an archive
+type archiveWriter interface {
+	addDir(name string, stat os.FileInfo) error
+	addFile(name string, stat os.FileInfo, r io.Reader) error
+	Close() error
+}
+
+// archiveFormat is the content type, extension and writer
+// of an archive format
+type archiveFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(w io.Writer) archiveWriter
+}
+
+// archiveFormats are the supported archive formats
+var archiveFormats = map[string]archiveFormat{
+	"zip":    {"application/zip", ".zip", newZipArchive},
+	"tar.gz": {"application/gzip", ".tar.gz", newTarGzArchive},
+}
+
+// zipArchive writes a zip archive
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func newZipArchive(w io.Writer) archiveWriter {
+	return &zipArchive{zw: zip.NewWriter(w)}
+}
+
+func (a *zipArchive) addDir(name string, stat os.FileInfo) (err error) {
+	header, err := zip.FileInfoHeader(stat)
+	if err != nil {
+		return
+	}
+	header.Name = name + "/"
+	_, err = a.zw.CreateHeader(header)
+	return
+}
+
+func (a *zipArchive) addFile(name string, stat os.FileInfo, r io.Reader) (err error) {
+	header, err := zip.FileInfoHeader(stat)
+	if err != nil {
+		return
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	w, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(w, r)
+	return
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
+
+// tarGzArchive writes a gzip compressed tar archive
+type tarGzArchive struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarGzArchive(w io.Writer) archiveWriter {
+	gw := gzip.NewWriter(w)
+	return &tarGzArchive{gw: gw, tw: tar.NewWriter(gw)}
+}
+
+func (a *tarGzArchive) addDir(name string, stat os.FileInfo) (err error) {
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return
+	}
+	header.Name = name + "/"
+	return a.tw.WriteHeader(header)
+}
+
+func (a *tarGzArchive) addFile(name string, stat os.FileInfo, r io.Reader) (err error) {
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return
+	}
+	header.Name = name
+	if err = a.tw.WriteHeader(header); err != nil {
+		return
+	}
+	_, err = io.CopyN(a.tw, r, stat.Size())
+	return
+}
+
+func (a *tarGzArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gw.Close()
+}
+
+// handleArchive streams an archive of the directory at the request
+// path, in the format of the format query (zip by default). Symlinks
+// are followed, unless NoFollowSymlinks is configured, in which case
+// they are skipped. Special files are always skipped.
+func handleArchive(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// only read methods are allowed
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		ctx := withFilesystem(withEndpointContext(r.Context(), r), cfg.Root)
+		ctx = withConfig(ctx, cfg)
+
+		path, err := scopePath(r.URL.Path)
+		if err != nil {
+			writeStatError(w, r, err)
+			return
+		}
+
+		formatName := r.URL.Query().Get("format")
+		if formatName == "" {
+			formatName = "zip"
+		}
+		format, ok := archiveFormats[formatName]
+		if !ok {
+			writeStatError(w, r, NewStatError(http.StatusBadRequest, path))
+			return
+		}
+
+		d, stat, err := openFile(ctx, path)
+		if err != nil {
+			writeStatError(w, r, err)
+			return
+		}
+		d.Close()
+		if !stat.IsDir() {
+			writeStatError(w, r, NewStatError(http.StatusBadRequest, path))
+			return
+		}
+
+		// fail while the response can still report it, if timed out
+		if err = ctx.Err(); err != nil {
+			if err = contextError(ctx, err, path); err.(*StatError).Code != StatusClientClosedRequest {
+				cfg.setRetryAfter(w)
+			}
+			writeStatError(w, r, err)
+			return
+		}
+
+		name := stat.Name()
+		if name == "" || name == "/" || name == "." {
+			name = "archive"
+		}
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": name + format.extension,
+		}))
+		if conditionalArchive(r) && serveBufferedArchive(ctx, w, r, format, path) {
+			return
+		}
+
+		// streamed, without ETag nor ranges, unless conditional
+		// and small enough to buffer
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		aw := format.newWriter(w)
+		if err = walkArchive(ctx, aw, path, "", nil); err == nil {
+			err = aw.Close()
+		}
+		if err != nil {
+			cfg.logger().Error("error archiving path", "path", path, "error", err)
+		}
+	}
+}
+
+// conditionalArchive reports if the archive request is for a range or
+// conditional on an entity tag, worth buffering the archive for
+func conditionalArchive(r *http.Request) bool {
+	for _, header := range []string{"Range", "If-Range", "If-None-Match"} {
+		if r.Header.Get(header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// serveBufferedArchive serves the archive of the directory at path,
+// or the range requested of it, materialized in a temporary file, so an
+// interrupted download can be resumed. Archives are deterministic for an
+// unchanged tree, and the strong ETag of the archive content, sent with
+// full responses conditional on If-None-Match too, lets clients check
+// with If-Range that the tree did not change since. Reports false,
+// nothing written, if the archive is larger than the configured buffer.
+func serveBufferedArchive(ctx context.Context, w http.ResponseWriter, r *http.Request, format archiveFormat, path string) bool {
+
+	tmp, err := os.CreateTemp("", "goserve-archive-*")
+	if err == nil {
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		sum := sha256.New()
+		limited := &limitedWriter{w: io.MultiWriter(tmp, sum), n: getConfig(ctx).maxArchiveBuffer()}
+		aw := format.newWriter(limited)
+		if err = walkArchive(ctx, aw, path, "", nil); err == nil {
+			err = aw.Close()
+		}
+		if errors.Is(err, errArchiveBufferFull) {
+			return false
+		}
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("ETag", "\""+hex.EncodeToString(sum.Sum(nil))+"\"")
+	}
+	if err != nil {
+		getConfig(ctx).logger().Error("error archiving path", "path", path, "error", err)
+		for _, header := range []string{"Content-Type", "Content-Disposition", "Accept-Ranges", "ETag"} {
+			w.Header().Del(header)
+		}
+		if err = contextError(ctx, err, path); !errors.As(err, new(*StatError)) {
+			err = NewStatError(http.StatusInternalServerError, path)
+		}
+		writeStatError(w, r, err)
+		return true
+	}
+
+	http.ServeContent(w, r, "", time.Time{}, tmp)
+	return true
+}
+
+// walkArchive adds the entries of the directory at the scoped path to
+// the archive, recursively, named under prefix. ancestors are the
+// directories already visited in this branch.
+func walkArchive(ctx context.Context, aw archiveWriter, scoped, prefix string, ancestors []os.FileInfo) (err error) {
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	d, stat, err := openFile(ctx, scoped)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	// followed symlinks may lead back to an ancestor directory
+	for _, ancestor := range ancestors {
+		if os.SameFile(ancestor, stat) {
+			return
+		}
+	}
+
+	files, err := readDir(d)
+	if err != nil {
+		return
+	}
+	if err = sortFiles(ctx, files); err != nil {
+		return
+	}
+
+	cfg := getConfig(ctx)
+	for _, item := range files {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if cfg.hiddenEntry(item) {
+			continue
+		}
+		if item.Mode()&os.ModeSymlink != 0 && cfg.NoFollowSymlinks {
+			continue
+		}
+		itemPath := childPath(scoped, item.Name())
+		itemName := path.Join(prefix, item.Name())
+		if err = addArchiveEntry(ctx, aw, itemPath, itemName, append(ancestors, stat)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// addArchiveEntry adds the file or directory at the scoped path to the
+// archive as name, following symlinks. Special files, and entries that
+// cannot be opened, are skipped.
+func addArchiveEntry(ctx context.Context, aw archiveWriter, scoped, name string, ancestors []os.FileInfo) (err error) {
+
+	file, stat, oerr := openFile(ctx, scoped)
+	if oerr != nil {
+		return
+	}
+	defer file.Close()
+
+	switch {
+	case stat.IsDir():
+		if err = aw.addDir(name, stat); err != nil {
+			return
+		}
+		return walkArchive(ctx, aw, scoped, name, ancestors)
+	case stat.Mode().IsRegular():
+		return aw.addFile(name, stat, file)
+	}
+	return
+}
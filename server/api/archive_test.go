@@ -0,0 +1,243 @@
+package api_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// archiveSizes are the sizes of the files archived from testRoot
+var archiveSizes = map[string]int64{
+	"hello.txt":         6,
+	"folder/":           0,
+	"folder/nested.txt": 7,
+}
+
+func TestArchiveEndpoint_zip(t *testing.T) {
+
+	w := serveAPI(http.Dir(testRoot), "/api/archive/")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := "application/zip", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+	if want, have := `attachment; filename=root.zip`, w.Header().Get("Content-Disposition"); want != have {
+		t.Errorf("expected Content-Disposition %#v, got %#v", want, have)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("error reading zip: %s", err)
+	}
+	found := make(map[string]int64)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("error opening %s: %s", f.Name, err)
+		}
+		n, _ := io.Copy(io.Discard, rc)
+		rc.Close()
+		found[f.Name] = n
+	}
+	assertArchive(t, found)
+}
+
+func TestArchiveEndpoint_tarGz(t *testing.T) {
+
+	w := serveAPI(http.Dir(testRoot), "/api/archive/?format=tar.gz")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := "application/gzip", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("error reading gzip: %s", err)
+	}
+	tr := tar.NewReader(gr)
+	found := make(map[string]int64)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error reading tar: %s", err)
+		}
+		n, _ := io.Copy(io.Discard, tr)
+		found[header.Name] = n
+	}
+	assertArchive(t, found)
+}
+
+// assertArchive compares the entry sizes found against archiveSizes
+func assertArchive(t *testing.T, found map[string]int64) {
+	if want, have := len(archiveSizes), len(found); want != have {
+		t.Errorf("expected %d entries, got %#v", want, found)
+	}
+	for name, size := range archiveSizes {
+		if have, ok := found[name]; !ok {
+			t.Errorf("expected entry %#v", name)
+		} else if size != have {
+			t.Errorf("%s: expected size %d, got %d", name, size, have)
+		}
+	}
+}
+
+func TestArchiveEndpoint_symlinks(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Symlink("file.txt", filepath.Join(root, "link.txt")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Symlink(".", filepath.Join(root, "loop")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		noFollow bool
+		entries  []string
+	}{
+		{"followed", false, []string{"file.txt", "link.txt", "loop/"}},
+		{"skipped", true, []string{"file.txt"}},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/api/archive/", nil)
+		w := serveRequest(http.Dir(root), req, api.WithNoFollowSymlinks(test.noFollow))
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if err != nil {
+			t.Fatalf("%s: error reading zip: %s", test.name, err)
+		}
+		names := make(map[string]bool)
+		for _, f := range zr.File {
+			names[f.Name] = true
+		}
+		if want, have := len(test.entries), len(names); want != have {
+			t.Errorf("%s: expected %d entries, got %#v", test.name, want, names)
+		}
+		for _, name := range test.entries {
+			if !names[name] {
+				t.Errorf("%s: expected entry %#v in %#v", test.name, name, names)
+			}
+		}
+	}
+}
+
+func TestArchiveEndpoint_errors(t *testing.T) {
+	for target, code := range map[string]int{
+		"/api/archive/hello.txt":   http.StatusBadRequest,
+		"/api/archive/?format=rar": http.StatusBadRequest,
+		"/api/archive/missing":     http.StatusNotFound,
+		"/api/archive/../outside":  http.StatusForbidden,
+	} {
+		w := serveAPI(http.Dir(testRoot), target)
+		if want, have := code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+	}
+}
+
+func TestArchiveEndpoint_range(t *testing.T) {
+
+	// streamed, not buffered, without validators
+	streamed := serveAPI(http.Dir(testRoot), "/api/archive/")
+	for _, header := range []string{"ETag", "Accept-Ranges"} {
+		if have := streamed.Header().Get(header); have != "" {
+			t.Errorf("expected no %s of the streamed archive, got %#v", header, have)
+		}
+	}
+
+	// buffered, and tagged, if conditional
+	req := httptest.NewRequest("GET", "/api/archive/", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	full := serveRequest(http.Dir(testRoot), req)
+	if want, have := "bytes", full.Header().Get("Accept-Ranges"); want != have {
+		t.Errorf("expected Accept-Ranges %#v, got %#v", want, have)
+	}
+	archive := full.Body.Bytes()
+	if !bytes.Equal(streamed.Body.Bytes(), archive) {
+		t.Errorf("expected the buffered archive to be the streamed one")
+	}
+	req = httptest.NewRequest("GET", "/api/archive/", nil)
+	req.Header.Set("If-None-Match", full.Header().Get("ETag"))
+	if want, have := http.StatusNotModified, serveRequest(http.Dir(testRoot), req).Code; want != have {
+		t.Errorf("expected status %d of the unchanged archive, got %d", want, have)
+	}
+
+	req = httptest.NewRequest("GET", "/api/archive/", nil)
+	req.Header.Set("Range", "bytes=10-99")
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusPartialContent, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := fmt.Sprintf("bytes 10-99/%d", len(archive)), w.Header().Get("Content-Range"); want != have {
+		t.Errorf("expected Content-Range %#v, got %#v", want, have)
+	}
+	if want, have := "application/zip", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+	if !bytes.Equal(archive[10:100], w.Body.Bytes()) {
+		t.Errorf("expected range of the full archive, got %#v", w.Body.String())
+	}
+
+	// resumed only if the archive did not change, as tagged in full
+	etag := w.Header().Get("ETag")
+	if want, have := etag, full.Header().Get("ETag"); want == "" || want != have {
+		t.Errorf("expected ETag %#v of the full archive, got %#v", want, have)
+	}
+	for _, test := range []struct {
+		ifRange string
+		code    int
+	}{
+		{etag, http.StatusPartialContent},
+		{`"stale"`, http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/api/archive/", nil)
+		req.Header.Set("Range", "bytes=10-")
+		req.Header.Set("If-Range", test.ifRange)
+		w := serveRequest(http.Dir(testRoot), req)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("If-Range %s: expected status %d, got %d", test.ifRange, want, have)
+		}
+	}
+}
+
+func TestArchiveEndpoint_rangeTooLarge(t *testing.T) {
+
+	// larger than the buffer, streamed in full whatever the range
+	req := httptest.NewRequest("GET", "/api/archive/", nil)
+	req.Header.Set("Range", "bytes=10-99")
+	w := serveRequest(http.Dir(testRoot), req, api.WithMaxArchiveBuffer(100))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	for _, header := range []string{"ETag", "Accept-Ranges"} {
+		if have := w.Header().Get(header); have != "" {
+			t.Errorf("expected no %s, got %#v", header, have)
+		}
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("error reading zip: %s", err)
+	}
+	if want, have := 3, len(zr.File); want != have {
+		t.Errorf("expected %d archived entries, got %d", want, have)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrUnauthenticated is returned, or wrapped, by an Authorize hook to
+// reject a request without credentials, or with invalid ones, which is
+// then unauthorized. Any other error of the hook is forbidden.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// authorizeStatus returns the status code of the rejection of a
+// request by the Authorize hook, or 0 if the request is authorized
+func (cfg *Config) authorizeStatus(r *http.Request) int {
+	if cfg.Authorize == nil {
+		return 0
+	}
+	err := cfg.Authorize(r)
+	if err == nil {
+		return 0
+	}
+	var statErr *StatError
+	if errors.As(err, &statErr) && (statErr.Code == http.StatusUnauthorized || statErr.Code == http.StatusForbidden) {
+		return statErr.Code
+	}
+	if errors.Is(err, ErrUnauthenticated) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusForbidden
+}
+
+// authChallenge returns the configured challenge of requests
+// unauthorized
+func (cfg *Config) authChallenge() string {
+	if cfg.AuthChallenge == "" {
+		return "Bearer"
+	}
+	return cfg.AuthChallenge
+}
+
+// authorize writes the rejection of a request by the Authorize hook
+// and reports if it did, the request then not to be served
+func (cfg *Config) authorize(w http.ResponseWriter, r *http.Request) bool {
+	code := cfg.authorizeStatus(r)
+	if code == 0 {
+		return false
+	}
+	cfg.logger().Info("request not authorized", "path", r.URL.Path, "status", code)
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", cfg.authChallenge())
+	}
+	writeError(w, r, code, http.StatusText(code))
+	return true
+}
@@ -0,0 +1,88 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// tokenAuthorizer authorizes the requests with the bearer token
+func tokenAuthorizer(token string) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			return api.ErrUnauthenticated
+		}
+		if auth != "Bearer "+token {
+			return errors.New("invalid token")
+		}
+		return nil
+	}
+}
+
+func TestWithAuthorize(t *testing.T) {
+
+	tests := []struct {
+		target string
+		auth   string
+		accept string
+		code   int
+	}{
+		{"/api/stats/hello.txt", "", "", http.StatusUnauthorized},
+		{"/api/stats/hello.txt", "Bearer wrong", "", http.StatusForbidden},
+		{"/api/stats/hello.txt", "Bearer secret", "", http.StatusOK},
+		{"/api/list/folder", "", "", http.StatusUnauthorized},
+		{"/api/nosuchendpoint", "", "", http.StatusUnauthorized},
+		{"/hello.txt", "", "application/goserve+json", http.StatusUnauthorized},
+		{"/hello.txt", "Bearer secret", "application/goserve+json", http.StatusOK},
+		{"/hello.txt", "", "", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		w := serveRequest(http.Dir(testRoot), req, api.WithAuthorize(tokenAuthorizer("secret")))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s (%q): expected status %d, got %d", test.target, test.auth, want, have)
+		}
+		challenge := ""
+		if test.code == http.StatusUnauthorized {
+			challenge = "Bearer"
+		}
+		if want, have := challenge, w.Header().Get("WWW-Authenticate"); want != have {
+			t.Errorf("%s (%q): expected WWW-Authenticate %#v, got %#v", test.target, test.auth, want, have)
+		}
+	}
+}
+
+func TestWithAuthChallenge(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	w := serveRequest(http.Dir(testRoot), req,
+		api.WithAuthorize(tokenAuthorizer("secret")),
+		api.WithAuthChallenge(`Basic realm="files"`))
+	if want, have := http.StatusUnauthorized, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := `Basic realm="files"`, w.Header().Get("WWW-Authenticate"); want != have {
+		t.Errorf("expected WWW-Authenticate %#v, got %#v", want, have)
+	}
+}
+
+func TestWithAuthorize_statError(t *testing.T) {
+	authorize := func(r *http.Request) error {
+		return api.NewStatError(http.StatusUnauthorized, "")
+	}
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	w := serveRequest(http.Dir(testRoot), req, api.WithAuthorize(authorize))
+	if want, have := http.StatusUnauthorized, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
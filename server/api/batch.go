@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// decodeBatchStats decodes the paths of a batch stats request, from
+// a JSON array in the body of POST request, or a comma separated
+// paths query otherwise
+func decodeBatchStats(r *http.Request) (req interface{}, err error) {
+	var paths []string
+	if r.Method == http.MethodPost {
+		if err = json.NewDecoder(r.Body).Decode(&paths); err != nil {
+			err = bodyError(err, r.URL.Path)
+			return
+		}
+	} else {
+		paths = strings.Split(r.URL.Query().Get("paths"), ",")
+	}
+	req = paths
+	return
+}
+
+// batchStatsEndpoint returns the stats of each path in the same order.
+// Failure of a path is reported as StatError in place of its stats.
+func batchStatsEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
+
+	paths := req.([]string)
+	results := make([]interface{}, len(paths))
+	for i, path := range paths {
+		stats, statErr := statsEndpoint(ctx, strings.TrimLeft(path, "/"))
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+			return
+		}
+		switch serr := statErr.(type) {
+		case nil:
+			results[i] = stats
+		case *StatError:
+			results[i] = serr
+		default:
+			results[i] = NewStatError(http.StatusInternalServerError, path)
+		}
+	}
+
+	resp = results
+	return
+}
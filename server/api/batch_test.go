@@ -0,0 +1,74 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchStatsEndpoint(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []struct {
+		typ  string
+		code float64
+	}{
+		{"file", 0},
+		{"", http.StatusNotFound},
+		{"directory", 0},
+		{"", http.StatusForbidden},
+	}
+
+	reqs := []*http.Request{
+		httptest.NewRequest("POST", "/api/stats", strings.NewReader(`["file.txt", "missing.txt", "/dir", "../secret"]`)),
+		httptest.NewRequest("GET", "/api/stats?paths=file.txt,missing.txt,dir,../secret", nil),
+	}
+	for _, req := range reqs {
+		w := serveRequest(http.Dir(root), req)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", req.Method, want, have)
+			continue
+		}
+
+		var results []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+			t.Fatalf("%s: error decoding response: %s", req.Method, err)
+		}
+		if want, have := len(expected), len(results); want != have {
+			t.Fatalf("%s: expected %d results, got %d", req.Method, want, have)
+		}
+		for i, exp := range expected {
+			if exp.typ != "" {
+				if want, have := exp.typ, results[i]["type"]; want != have {
+					t.Errorf("%s: results[%d]: expected type %#v, got %#v", req.Method, i, want, have)
+				}
+				continue
+			}
+			if want, have := "error", results[i]["status"]; want != have {
+				t.Errorf("%s: results[%d]: expected status %#v, got %#v", req.Method, i, want, have)
+			}
+			if want, have := exp.code, results[i]["code"]; want != have {
+				t.Errorf("%s: results[%d]: expected code %#v, got %#v", req.Method, i, want, have)
+			}
+		}
+	}
+}
+
+func TestBatchStatsEndpoint_invalid(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/stats", strings.NewReader(`{"not": "an array"}`))
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusBadRequest, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
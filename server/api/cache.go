@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// statValidators returns the Last-Modified time and ETag of a
+// FileStat or DirStat response. The ETag is weak unless the strong
+// one of the file content is computed. ok is false for other responses.
+func statValidators(resp interface{}) (modTime time.Time, etag string, ok bool) {
+	switch stat := resp.(type) {
+	case fieldSelection:
+		return statValidators(stat.Stat)
+	case FileStat:
+		modTime = stat.MTime
+		etag = weakETag(stat.Size, stat.MTime)
+		if stat.contentTag != "" {
+			etag = stat.contentTag
+		}
+		ok = true
+	case DirStat:
+		modTime = stat.MTime
+		etag = weakETag(0, stat.MTime)
+		if stat.Entries != nil {
+			modTime, etag = newestModTime(stat), listingETag(stat)
+		}
+		if stat.Usage != nil || stat.EntryCount != nil {
+			etag = aggregateETag(etag, stat)
+		}
+
+		// the usage of the tree changes with nested entries, which the
+		// modification time of the directory does not reflect
+		if stat.Usage != nil {
+			modTime = time.Time{}
+		}
+		ok = true
+	}
+	return
+}
+
+// aggregateETag computes a weak entity tag of a directory from its
+// entity tag and the aggregates of its entries, usage and count, so it
+// changes with them
+func aggregateETag(etag string, dir DirStat) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00", etag)
+	if dir.Usage != nil {
+		fmt.Fprintf(h, "%d\x00%d\x00", dir.Usage.TotalSize, dir.Usage.FileCount)
+	}
+	if dir.EntryCount != nil {
+		fmt.Fprintf(h, "%d\x00%t\x00", *dir.EntryCount, dir.EntryCountTruncated)
+	}
+	return fmt.Sprintf("W/\"%x-%x\"", dir.MTime.UnixNano(), h.Sum64())
+}
+
+// listingETag computes a weak entity tag of a directory listing, or
+// tree, from the modification time of the directory and a hash of the
+// names, sizes and modification times of its entries, nested ones
+// included, so it changes when any entry is added, removed or modified
+func listingETag(dir DirStat) string {
+	h := fnv.New64a()
+	hashEntries(h, dir.Entries)
+	return fmt.Sprintf("W/\"%x-%x\"", dir.MTime.UnixNano(), h.Sum64())
+}
+
+// newestModTime returns the latest modification time of the directory
+// and of its entries, nested ones included, so that a listing or tree
+// is modified since whenever any of its entries is
+func newestModTime(dir DirStat) time.Time {
+	newest := dir.MTime
+	for _, entry := range dir.Entries {
+		modTime := newest
+		switch entry := entry.(type) {
+		case FileStat:
+			modTime = entry.MTime
+		case DirStat:
+			modTime = newestModTime(entry)
+		}
+		if modTime.After(newest) {
+			newest = modTime
+		}
+	}
+	return newest
+}
+
+// hashEntries writes the names, sizes and modification times of the
+// entries to h, recursively for the entries of nested directories
+func hashEntries(h io.Writer, entries []interface{}) {
+	for _, entry := range entries {
+		switch entry := entry.(type) {
+		case FileStat:
+			fmt.Fprintf(h, "%s\x00%d\x00%d\x00", entry.Name, entry.Size, entry.MTime.UnixNano())
+		case DirStat:
+			fmt.Fprintf(h, "%s/\x00%d\x00%t\x00", entry.Name, entry.MTime.UnixNano(), entry.Truncated)
+			if entry.Entries != nil {
+				fmt.Fprint(h, "{\x00")
+				hashEntries(h, entry.Entries)
+				fmt.Fprint(h, "}\x00")
+			}
+		case *StatError:
+			fmt.Fprintf(h, "%s\x00!%d\x00", entry.Path, entry.Code)
+		}
+	}
+}
+
+// weakETag computes a weak entity tag from size and modification time
+func weakETag(size int64, modTime time.Time) string {
+	return fmt.Sprintf("W/\"%x-%x\"", size, modTime.UnixNano())
+}
+
+// contentTagAlgorithm is the checksum algorithm of strong entity tags
+const contentTagAlgorithm = "sha256"
+
+// strongETag formats the checksum of content as strong entity tag
+func strongETag(sum *Checksum) string {
+	return "\"" + sum.Digest + "\""
+}
+
+// contentETag computes the strong entity tag from the content of the
+// file, and seeks the file back to its start. The content is always
+// hashed, not the checksum cached for its size and modification time,
+// so that the tag changes with the content whatever its metadata.
+func contentETag(ctx context.Context, path string, stat os.FileInfo, file http.File) (etag string, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	sum, err := newChecksum(contentTagAlgorithm, file)
+	if err != nil {
+		return
+	}
+	getConfig(ctx).checksums.put(path, contentTagAlgorithm, stat, sum)
+	etag = strongETag(sum)
+	_, err = file.Seek(0, io.SeekStart)
+	return
+}
+
+// notModified reports if the client's cached version, as described
+// by If-None-Match or If-Modified-Since of the request, is current
+func notModified(r *http.Request, modTime time.Time, etag string) bool {
+
+	// If-None-Match takes precedence over If-Modified-Since
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, etag)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		// HTTP dates have a resolution of a second
+		return !modTime.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// preconditionFailed reports if the If-Match or If-Unmodified-Since
+// precondition of the request fails for the current validators of
+// the resource, or for a resource that does not exist. Entity tags
+// are compared weakly, as the validators of stats are weak unless
+// strong ones are configured.
+func preconditionFailed(header http.Header, modTime time.Time, etag string, exists bool) bool {
+
+	// If-Match takes precedence over If-Unmodified-Since
+	if im := header.Get("If-Match"); im != "" {
+		return !exists || !etagMatch(im, etag)
+	}
+
+	if ius := header.Get("If-Unmodified-Since"); ius != "" && exists && !modTime.IsZero() {
+		since, err := http.ParseTime(ius)
+		if err != nil {
+			return false
+		}
+		// HTTP dates have a resolution of a second
+		return modTime.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// etagMatch does a weak comparison of etag against a
+// comma separated list of entity tags (or "*")
+func etagMatch(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,340 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestStatsEndpoint_etag(t *testing.T) {
+
+	w := serveAPI(http.Dir(testRoot), "/api/stats/hello.txt")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header")
+	}
+	if w.Header().Get("Last-Modified") == "" {
+		t.Errorf("expected Last-Modified header")
+	}
+
+	// replay with the ETag captured
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusNotModified, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := 0, w.Body.Len(); want != have {
+		t.Errorf("expected empty body, got %#v", w.Body.String())
+	}
+
+	// replay with a stale ETag
+	req = httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	req.Header.Set("If-None-Match", `W/"stale"`)
+	w = serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestStatsEndpoint_lastModified(t *testing.T) {
+
+	w := serveAPI(http.Dir(testRoot), "/api/stats/folder")
+	lastModified := w.Header().Get("Last-Modified")
+	if lastModified == "" {
+		t.Fatalf("expected Last-Modified header")
+	}
+
+	// replay with the Last-Modified captured
+	req := httptest.NewRequest("GET", "/api/stats/folder", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	w = serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusNotModified, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// replay with an earlier time
+	req = httptest.NewRequest("GET", "/api/stats/folder", nil)
+	req.Header.Set("If-Modified-Since", "Mon, 01 Jan 2001 00:00:00 GMT")
+	w = serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestWithStrongETag(t *testing.T) {
+
+	root := t.TempDir()
+	name := filepath.Join(root, "file.txt")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	write := func(content string) {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	etags := func(target string) (weak, strong string) {
+		weak = serveAPI(http.Dir(root), target).Header().Get("ETag")
+		req := httptest.NewRequest("GET", target, nil)
+		strong = serveRequest(http.Dir(root), req, api.WithStrongETag(true)).Header().Get("ETag")
+		return
+	}
+
+	for _, target := range []string{"/api/stats/file.txt", "/api/read/file.txt"} {
+		write("hello")
+		weak1, strong1 := etags(target)
+		write("world") // same size and mtime
+		weak2, strong2 := etags(target)
+
+		if strings.HasPrefix(strong1, "W/") || strong1 == "" {
+			t.Errorf("%s: expected strong ETag, got %#v", target, strong1)
+		}
+		if strong1 == strong2 {
+			t.Errorf("%s: expected strong ETag to change with content, got %#v", target, strong1)
+		}
+		if weak1 != weak2 {
+			t.Errorf("%s: expected weak ETag %#v not to change, got %#v", target, weak1, weak2)
+		}
+
+		// conditional request against the strong ETag
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("If-None-Match", strong2)
+		w := serveRequest(http.Dir(root), req, api.WithStrongETag(true))
+		if want, have := http.StatusNotModified, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+	}
+}
+
+func TestListEndpoint_etag(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	w := serveAPI(http.Dir(root), "/api/list/")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header")
+	}
+
+	// replay with the ETag captured before the change
+	req := httptest.NewRequest("GET", "/api/list/", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = serveRequest(http.Dir(root), req)
+	if want, have := http.StatusNotModified, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// add an entry, keeping the modification time of the directory
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Chtimes(root, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	req = httptest.NewRequest("GET", "/api/list/", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = serveRequest(http.Dir(root), req)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if have := w.Header().Get("ETag"); have == etag {
+		t.Errorf("expected ETag to change from %#v", etag)
+	}
+}
+
+func TestTreeEndpoint_etag(t *testing.T) {
+
+	root := t.TempDir()
+	deep := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(deep, 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(deep, "c.txt"), []byte("c"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	w := serveAPI(http.Dir(root), "/api/tree/")
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header")
+	}
+
+	// tests of deep changes, keeping the times of the directories
+	tests := []struct {
+		desc   string
+		change func() error
+	}{
+		{"resized", func() error {
+			return os.WriteFile(filepath.Join(deep, "c.txt"), []byte("cc"), 0644)
+		}},
+		{"renamed", func() error {
+			return os.Rename(filepath.Join(deep, "c.txt"), filepath.Join(deep, "d.txt"))
+		}},
+		{"added", func() error {
+			return os.WriteFile(filepath.Join(deep, "e.txt"), []byte("e"), 0644)
+		}},
+	}
+	for _, test := range tests {
+		info, err := os.Stat(deep)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := test.change(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.Chtimes(deep, info.ModTime(), info.ModTime()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		req := httptest.NewRequest("GET", "/api/tree/", nil)
+		req.Header.Set("If-None-Match", etag)
+		w = serveRequest(http.Dir(root), req)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.desc, want, have)
+		}
+		if have := w.Header().Get("ETag"); have == etag {
+			t.Errorf("%s: expected ETag to change from %#v", test.desc, etag)
+		}
+		etag = w.Header().Get("ETag")
+	}
+}
+
+func TestListEndpoint_lastModified(t *testing.T) {
+
+	root := t.TempDir()
+	deep := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(deep, 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	name := filepath.Join(deep, "c.txt")
+	if err := os.WriteFile(name, []byte("c"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	touch := func(name string, year int) {
+		modTime := time.Date(year, 1, 2, 3, 4, 5, 0, time.UTC)
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	touch(name, 2021)
+	for _, dir := range []string{deep, filepath.Join(root, "a"), root} {
+		touch(dir, 2020)
+	}
+
+	// modified as the newest entry of the body
+	tests := []struct {
+		target       string
+		lastModified string
+	}{
+		{"/api/tree/", "Sat, 02 Jan 2021 03:04:05 GMT"},
+		{"/api/list/a/b", "Sat, 02 Jan 2021 03:04:05 GMT"},
+		{"/api/list/", "Thu, 02 Jan 2020 03:04:05 GMT"},
+	}
+	for _, test := range tests {
+		w := serveAPI(http.Dir(root), test.target)
+		if want, have := test.lastModified, w.Header().Get("Last-Modified"); want != have {
+			t.Errorf("%s: expected Last-Modified %#v, got %#v", test.target, want, have)
+		}
+	}
+
+	// a nested change is modified since
+	touch(name, 2022)
+	req := httptest.NewRequest("GET", "/api/tree/", nil)
+	req.Header.Set("If-Modified-Since", "Sat, 02 Jan 2021 03:04:05 GMT")
+	w := serveRequest(http.Dir(root), req)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestStatsEndpoint_recursiveETag(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "dir", "sub"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "dir", "sub", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	w := serveAPI(http.Dir(root), "/api/stats/dir?recursive=true")
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header")
+	}
+	if have := w.Header().Get("Last-Modified"); have != "" {
+		t.Errorf("expected no Last-Modified of aggregated usage, got %#v", have)
+	}
+
+	// a nested change leaves the time of the directory unchanged
+	if err := os.WriteFile(filepath.Join(root, "dir", "sub", "b.txt"), []byte("b"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, header := range []string{"If-None-Match", "If-Modified-Since"} {
+		req := httptest.NewRequest("GET", "/api/stats/dir?recursive=true", nil)
+		if header == "If-None-Match" {
+			req.Header.Set(header, etag)
+		} else {
+			req.Header.Set(header, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		}
+		w = serveRequest(http.Dir(root), req)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", header, want, have)
+		}
+		if want, have := float64(2), decodeBody(t, w)["fileCount"]; want != have {
+			t.Errorf("%s: expected fileCount %#v, got %#v", header, want, have)
+		}
+	}
+}
+
+func TestWithCacheControl(t *testing.T) {
+
+	opt := api.WithCacheControl("max-age=60")
+	tests := []struct {
+		target       string
+		code         int
+		cacheControl string
+	}{
+		{"/api/stats/hello.txt", http.StatusOK, "max-age=60"},
+		{"/api/list/folder", http.StatusOK, "max-age=60"},
+		{"/api/stats/missing.txt", http.StatusNotFound, "no-store"},
+	}
+	for _, test := range tests {
+		w := serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", test.target, nil), opt)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+		}
+		if want, have := test.cacheControl, w.Header().Get("Cache-Control"); want != have {
+			t.Errorf("%s: expected Cache-Control %#v, got %#v", test.target, want, have)
+		}
+	}
+
+	// no header by default
+	for _, target := range []string{"/api/stats/hello.txt", "/api/stats/missing.txt"} {
+		if have := serveAPI(http.Dir(testRoot), target).Header().Get("Cache-Control"); have != "" {
+			t.Errorf("%s: expected no Cache-Control by default, got %#v", target, have)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// resolveCase finds the entry of the parent directory of the scoped
+// path with a name matching its last element case-insensitively.
+// Returns StatError of not found if none matches, or conflict if more
+// than one does.
+func resolveCase(ctx context.Context, scoped string) (resolved string, err error) {
+
+	dir, base := path.Split(scoped)
+	dir = strings.TrimSuffix(dir, "/")
+
+	d, err := getFilesystem(ctx).Open("/" + dir)
+	if err != nil {
+		err = fileError(err, scoped)
+		return
+	}
+	defer d.Close()
+	files, err := readDir(d)
+	if err != nil {
+		err = fileError(err, scoped)
+		return
+	}
+
+	var matches []string
+	for _, file := range files {
+		if strings.EqualFold(file.Name(), base) {
+			matches = append(matches, file.Name())
+		}
+	}
+	switch len(matches) {
+	case 0:
+		err = NewStatError(http.StatusNotFound, scoped)
+	case 1:
+		resolved = childPath(dir, matches[0])
+	default:
+		err = NewStatError(http.StatusConflict, scoped)
+	}
+	return
+}
@@ -0,0 +1,52 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestWithCaseInsensitive(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, file := range []string{"README.md", "docs/Guide.md", "docs/guide.MD"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		target      string
+		insensitive bool
+		code        int
+		name        string
+	}{
+		{"/api/stats/readme.MD", true, http.StatusOK, "README.md"},
+		{"/api/stats/README.md", true, http.StatusOK, "README.md"},
+		{"/api/read/readme.md", true, http.StatusOK, ""},
+		{"/api/stats/readme.MD", false, http.StatusNotFound, ""},
+		{"/api/stats/docs/GUIDE.md", true, http.StatusConflict, ""},
+		{"/api/stats/missing.md", true, http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		w := serveRequest(http.Dir(root), req, api.WithCaseInsensitive(test.insensitive))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		if test.name != "" {
+			if want, have := test.name, decodeBody(t, w)["name"]; want != have {
+				t.Errorf("%s: expected name %#v, got %#v", test.target, want, have)
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Checksum is the digest of a file's content
+type Checksum struct {
+	Algorithm string `json:"algorithm"`
+	Digest    string `json:"digest"`
+}
+
+// hashers of supported checksum algorithms
+var hashers = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// newChecksum streams the content of r through the
+// hasher of the given algorithm
+func newChecksum(algorithm string, r io.Reader) (sum *Checksum, err error) {
+
+	newHash, ok := hashers[algorithm]
+	if !ok {
+		err = fmt.Errorf("unsupported hash algorithm %#v", algorithm)
+		return
+	}
+
+	h := newHash()
+	if _, err = io.Copy(h, r); err != nil {
+		return
+	}
+
+	sum = &Checksum{
+		Algorithm: algorithm,
+		Digest:    hex.EncodeToString(h.Sum(nil)),
+	}
+	return
+}
+
+// fileChecksum returns the checksum of the file at path, as read from
+// r, memoized by the checksum cache of the config if enabled
+func fileChecksum(ctx context.Context, path string, stat os.FileInfo, algorithm string, r io.Reader) (sum *Checksum, err error) {
+	cache := getConfig(ctx).checksums
+	if sum = cache.get(path, algorithm, stat); sum != nil {
+		return
+	}
+	if sum, err = newChecksum(algorithm, r); err != nil {
+		return
+	}
+	cache.put(path, algorithm, stat, sum)
+	return
+}
+
+// checksumKey identifies the checksums of a file by algorithm
+type checksumKey struct {
+	path      string
+	algorithm string
+}
+
+// checksumEntry is a checksum cached for the size and
+// modification time of the file when it was computed
+type checksumEntry struct {
+	key     checksumKey
+	size    int64
+	modTime time.Time
+	sum     Checksum
+}
+
+// checksumCache is a least recently used cache of checksums.
+// A nil cache caches nothing.
+type checksumCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[checksumKey]*list.Element
+}
+
+// newChecksumCache creates a cache of the given number of
+// checksums, or nil if size is not positive
+func newChecksumCache(size int) *checksumCache {
+	if size <= 0 {
+		return nil
+	}
+	return &checksumCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[checksumKey]*list.Element),
+	}
+}
+
+// get returns the cached checksum of the file, or nil if not cached
+// or computed for a different size or modification time
+func (cache *checksumCache) get(path, algorithm string, stat os.FileInfo) *Checksum {
+	if cache == nil {
+		return nil
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	elem, ok := cache.entries[checksumKey{path, algorithm}]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*checksumEntry)
+	if entry.size != stat.Size() || !entry.modTime.Equal(stat.ModTime()) {
+		cache.order.Remove(elem)
+		delete(cache.entries, entry.key)
+		return nil
+	}
+	cache.order.MoveToFront(elem)
+	sum := entry.sum
+	return &sum
+}
+
+// put caches the checksum of the file, evicting the least
+// recently used one if full
+func (cache *checksumCache) put(path, algorithm string, stat os.FileInfo, sum *Checksum) {
+	if cache == nil {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	key := checksumKey{path, algorithm}
+	entry := &checksumEntry{key: key, size: stat.Size(), modTime: stat.ModTime(), sum: *sum}
+	if elem, ok := cache.entries[key]; ok {
+		elem.Value = entry
+		cache.order.MoveToFront(elem)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(entry)
+	if cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*checksumEntry).key)
+	}
+}
@@ -0,0 +1,158 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// countingFileSystem counts the bytes read from its files
+type countingFileSystem struct {
+	http.FileSystem
+	read atomic.Int64
+}
+
+func (fs *countingFileSystem) Open(name string) (http.File, error) {
+	file, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingFile{file, &fs.read}, nil
+}
+
+type countingFile struct {
+	http.File
+	read *atomic.Int64
+}
+
+func (file countingFile) Read(p []byte) (n int, err error) {
+	n, err = file.File.Read(p)
+	file.read.Add(int64(n))
+	return
+}
+
+func TestStatsEndpoint_checksum(t *testing.T) {
+
+	tests := []struct {
+		algorithm string
+		digest    string
+	}{
+		{"md5", "b1946ac92492d2347c6235b4d2611184"},
+		{"sha1", "f572d396fae9206628714fb2ce00f72e94f2258f"},
+		{"sha256", "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+	}
+
+	for _, test := range tests {
+		w := serveAPI(http.Dir(testRoot), "/api/stats/hello.txt?hash="+test.algorithm)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.algorithm, want, have)
+			continue
+		}
+		checksum, ok := decodeBody(t, w)["checksum"].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: expected checksum in response", test.algorithm)
+			continue
+		}
+		if want, have := test.algorithm, checksum["algorithm"]; want != have {
+			t.Errorf("%s: expected algorithm %#v, got %#v", test.algorithm, want, have)
+		}
+		if want, have := test.digest, checksum["digest"]; want != have {
+			t.Errorf("%s: expected digest %#v, got %#v", test.algorithm, want, have)
+		}
+	}
+}
+
+func TestStatsEndpoint_noChecksum(t *testing.T) {
+	w := serveAPI(http.Dir(testRoot), "/api/stats/hello.txt")
+	if _, ok := decodeBody(t, w)["checksum"]; ok {
+		t.Errorf("expected checksum to be omitted")
+	}
+}
+
+func TestStatsEndpoint_unsupportedChecksum(t *testing.T) {
+	w := serveAPI(http.Dir(testRoot), "/api/stats/hello.txt?hash=crc32")
+	if want, have := http.StatusBadRequest, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestWithChecksumCache(t *testing.T) {
+
+	root := t.TempDir()
+	name := filepath.Join(root, "data.txt")
+	if err := os.WriteFile(name, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fs := &countingFileSystem{FileSystem: http.Dir(root)}
+	h := api.ServeAPI("/api", fs, api.WithChecksumCache(10))(http.NotFoundHandler())
+
+	digest := func() interface{} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/api/stats/data.txt?hash=md5", nil))
+		checksum, _ := decodeBody(t, w)["checksum"].(map[string]interface{})
+		return checksum["digest"]
+	}
+
+	// hashed once for unchanged file
+	for i := 0; i < 2; i++ {
+		if want, have := "b1946ac92492d2347c6235b4d2611184", digest(); want != have {
+			t.Errorf("expected digest %#v, got %#v", want, have)
+		}
+	}
+	if want, have := int64(6), fs.read.Load(); want != have {
+		t.Errorf("expected %d bytes read, got %d", want, have)
+	}
+
+	// hashed again once modified
+	if err := os.WriteFile(name, []byte("world\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	modTime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want, have := "591785b794601e212b260e25925636fd", digest(); want != have {
+		t.Errorf("expected digest %#v, got %#v", want, have)
+	}
+	if want, have := int64(12), fs.read.Load(); want != have {
+		t.Errorf("expected %d bytes read, got %d", want, have)
+	}
+}
+
+func TestWithChecksumCache_strongETag(t *testing.T) {
+
+	root := t.TempDir()
+	name := filepath.Join(root, "data.txt")
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	write := func(content string) {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	h := api.ServeAPI("/api", http.Dir(root), api.WithChecksumCache(10), api.WithStrongETag(true))(http.NotFoundHandler())
+
+	// content changed with the same size and modification time
+	for _, target := range []string{"/api/read/data.txt", "/api/stats/data.txt?hash=sha256"} {
+		etag := func() string {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+			return w.Header().Get("ETag")
+		}
+		write("hello\n")
+		before := etag()
+		write("world\n")
+		after := etag()
+		if before == "" || before == after {
+			t.Errorf("%s: expected strong ETag to change with content, got %#v then %#v", target, before, after)
+		}
+	}
+}
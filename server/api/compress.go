@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder wraps w to write the content encoded, such as with Brotli
+// by github.com/andybalholm/brotli. Close flushes the encoded content.
+type Encoder func(w io.Writer) io.WriteCloser
+
+// builtin content encodings, in order of preference
+var encodings = []string{"gzip", "deflate"}
+
+// builtinEncoders are the encoders of the builtin content encodings
+var builtinEncoders = map[string]Encoder{
+	"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+	"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+}
+
+// acceptEncoding negotiates the content encoding of the response
+// from the Accept-Encoding header of the request. Additional encoders,
+// by name, are preferred over the builtin encodings, in lexical order.
+// Returns empty string if none of the encodings is acceptable.
+func acceptEncoding(r *http.Request, encoders map[string]Encoder) string {
+	accepted := parseQuality(r.Header.Get("Accept-Encoding"))
+	preferred := make([]string, 0, len(encoders)+len(encodings))
+	for name := range encoders {
+		if _, builtin := builtinEncoders[name]; !builtin {
+			preferred = append(preferred, name)
+		}
+	}
+	sort.Strings(preferred)
+	for _, encoding := range append(preferred, encodings...) {
+		if q, ok := accepted[encoding]; ok && q > 0 {
+			return encoding
+		}
+		if q, ok := accepted["*"]; ok && q > 0 {
+			if _, listed := accepted[encoding]; !listed {
+				return encoding
+			}
+		}
+	}
+	return ""
+}
+
+// parseQuality parses a comma separated header value with optional
+// quality factors (e.g. "gzip;q=0.8, deflate") into a map of
+// lowercased tokens to their quality
+func parseQuality(header string) map[string]float64 {
+	values := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		token := strings.ToLower(strings.TrimSpace(params[0]))
+		if token == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		values[token] = q
+	}
+	return values
+}
+
+// compress encodes the body with the given content encoding
+func compress(encoding string, encoders map[string]Encoder, body []byte) (compressed []byte, err error) {
+	buf := &bytes.Buffer{}
+	cw := compressWriter(encoding, encoders, buf)
+	if _, err = cw.Write(body); err != nil {
+		return
+	}
+	if err = cw.Close(); err != nil {
+		return
+	}
+	compressed = buf.Bytes()
+	return
+}
+
+// nopCloser is a writer with no-op Close
+type nopCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer
+func (nopCloser) Close() error { return nil }
+
+// compressWriter wraps w to encode with the given content encoding,
+// by the additional encoders first, then the builtin ones.
+// Unsupported encoding writes w as is.
+func compressWriter(encoding string, encoders map[string]Encoder, w io.Writer) io.WriteCloser {
+	if encoder, ok := encoders[encoding]; ok {
+		return encoder(w)
+	}
+	if encoder, ok := builtinEncoders[encoding]; ok {
+		return encoder(w)
+	}
+	return nopCloser{w}
+}
+
+// encodedETag derives the entity tag of an encoded representation
+func encodedETag(etag, encoding string) string {
+	if encoding == "" || !strings.HasSuffix(etag, "\"") {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + "\""
+}
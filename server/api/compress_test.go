@@ -0,0 +1,119 @@
+package api_test
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestWithCompression(t *testing.T) {
+
+	// uncompressed response
+	req := httptest.NewRequest("GET", "/api/list/", nil)
+	plain := serveRequest(http.Dir(testRoot), req, api.WithCompression(1))
+	if want, have := "", plain.Header().Get("Content-Encoding"); want != have {
+		t.Errorf("expected Content-Encoding %#v, got %#v", want, have)
+	}
+
+	tests := []struct {
+		encoding string
+		reader   func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"deflate", func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/api/list/", nil)
+		req.Header.Set("Accept-Encoding", test.encoding)
+		w := serveRequest(http.Dir(testRoot), req, api.WithCompression(1))
+		if want, have := test.encoding, w.Header().Get("Content-Encoding"); want != have {
+			t.Errorf("expected Content-Encoding %#v, got %#v", want, have)
+			continue
+		}
+		r, err := test.reader(w.Body)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.encoding, err)
+			continue
+		}
+		decoded, err := io.ReadAll(r)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.encoding, err)
+		}
+		if want, have := plain.Body.String(), string(decoded); want != have {
+			t.Errorf("%s: expected decoded body %#v, got %#v", test.encoding, want, have)
+		}
+		if plain.Header().Get("ETag") == w.Header().Get("ETag") {
+			t.Errorf("%s: expected ETag to differ from uncompressed response", test.encoding)
+		}
+	}
+}
+
+func TestWithCompression_threshold(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/list/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := serveRequest(http.Dir(testRoot), req, api.WithCompression(1<<20))
+	if want, have := "", w.Header().Get("Content-Encoding"); want != have {
+		t.Errorf("expected Content-Encoding %#v, got %#v", want, have)
+	}
+}
+
+func TestWithCompression_quality(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/list/", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0, deflate;q=0.5")
+	w := serveRequest(http.Dir(testRoot), req, api.WithCompression(1))
+	if want, have := "deflate", w.Header().Get("Content-Encoding"); want != have {
+		t.Errorf("expected Content-Encoding %#v, got %#v", want, have)
+	}
+}
+
+func TestWithEncoder(t *testing.T) {
+
+	// Brotli encoders are third-party. flate stands in for one, to
+	// decode the response without the dependency.
+	opts := []api.Option{
+		api.WithCompression(1),
+		api.WithEncoder("br", func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}),
+	}
+	plain := serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/list/", nil), opts...)
+
+	tests := []struct {
+		acceptEncoding string
+		opts           []api.Option
+		encoding       string
+	}{
+		{"br", opts, "br"},
+		{"gzip, deflate, br", opts, "br"},
+		{"gzip, br;q=0", opts, "gzip"},
+		{"gzip", opts, "gzip"},
+		{"br, gzip", []api.Option{api.WithCompression(1)}, "gzip"},
+		{"br", []api.Option{api.WithCompression(1)}, ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/api/list/", nil)
+		req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		w := serveRequest(http.Dir(testRoot), req, test.opts...)
+		if want, have := test.encoding, w.Header().Get("Content-Encoding"); want != have {
+			t.Errorf("%s: expected Content-Encoding %#v, got %#v", test.acceptEncoding, want, have)
+			continue
+		}
+		if test.encoding != "br" {
+			continue
+		}
+		decoded, err := io.ReadAll(flate.NewReader(w.Body))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.acceptEncoding, err)
+		}
+		if want, have := plain.Body.String(), string(decoded); want != have {
+			t.Errorf("%s: expected decoded body %#v, got %#v", test.acceptEncoding, want, have)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+)
+
+// concurrencyLimit bounds the concurrent requests of the handlers it
+// gates. Requests are not limited if nil.
+type concurrencyLimit chan struct{}
+
+// newConcurrencyLimit returns the limit of concurrent requests, or nil
+// if limit is not positive
+func newConcurrencyLimit(limit int) concurrencyLimit {
+	if limit <= 0 {
+		return nil
+	}
+	return make(concurrencyLimit, limit)
+}
+
+// gate serves the request with h if under the limit. Otherwise the
+// service is unavailable until an earlier request completes.
+func (sem concurrencyLimit) gate(cfg *Config, h http.HandlerFunc) http.HandlerFunc {
+	if sem == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			h(w, r)
+		default:
+			cfg.setRetryAfter(w)
+			writeStatError(w, r, NewStatError(http.StatusServiceUnavailable, r.URL.Path))
+		}
+	}
+}
+
+// expensiveStats reports if the stats request computes checksums,
+// aggregates the usage of a directory tree or counts all entries
+func expensiveStats(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Get("hash") != "" || query.Get("recursive") == "true" || query.Get("count") == "true"
+}
@@ -0,0 +1,92 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// blockingFileSystem blocks opening files until released
+type blockingFileSystem struct {
+	http.FileSystem
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (fs *blockingFileSystem) Open(name string) (http.File, error) {
+	fs.once.Do(func() { close(fs.entered) })
+	<-fs.release
+	return fs.FileSystem.Open(name)
+}
+
+func TestWithMaxExpensive(t *testing.T) {
+
+	fs := &blockingFileSystem{
+		FileSystem: http.Dir(testRoot),
+		entered:    make(chan struct{}),
+		release:    make(chan struct{}),
+	}
+	handler := api.ServeAPI("/api", fs, api.WithMaxExpensive(1))(http.NotFoundHandler())
+
+	// the first request holds the only slot until released
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(first, httptest.NewRequest("GET", "/api/tree/", nil))
+	}()
+	<-fs.entered
+
+	var wg sync.WaitGroup
+	targets := []string{
+		"/api/tree/",
+		"/api/archive/",
+		"/api/search/?q=hello",
+		"/api/stats/hello.txt?hash=sha256",
+		"/api/stats/?paths=hello.txt,folder&recursive=true",
+		"POST /api/stats?hash=sha256",
+	}
+	codes := make([]int, len(targets))
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			method, url, ok := strings.Cut(target, " ")
+			if !ok {
+				method, url = "GET", target
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(`["hello.txt"]`)))
+			codes[i] = w.Code
+			if w.Code == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+				t.Errorf("%s: expected Retry-After header", target)
+			}
+		}(i, target)
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if want, have := http.StatusServiceUnavailable, code; want != have {
+			t.Errorf("%s: expected status %d, got %d", targets[i], want, have)
+		}
+	}
+
+	close(fs.release)
+	<-done
+	if want, have := http.StatusOK, first.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// the slot is free again once the request completed
+	for _, target := range []string{"/api/tree/", "/api/archive/"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+	}
+}
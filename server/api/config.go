@@ -0,0 +1,751 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat determines how MTime is serialized in JSON
+type TimeFormat int
+
+const (
+	// TimeRFC3339Nano serializes time as RFC3339 with nanoseconds (default)
+	TimeRFC3339Nano TimeFormat = iota
+
+	// TimeRFC3339 serializes time as RFC3339 without sub-second precision
+	TimeRFC3339
+
+	// TimeRFC1123 serializes time as RFC1123 in UTC
+	TimeRFC1123
+
+	// TimeUnix serializes time as Unix epoch seconds
+	TimeUnix
+)
+
+// format returns the JSON value of t in the format
+func (format TimeFormat) format(t time.Time) interface{} {
+	switch format {
+	case TimeRFC3339:
+		return t.Format(time.RFC3339)
+	case TimeRFC1123:
+		return t.UTC().Format(time.RFC1123)
+	case TimeUnix:
+		return t.Unix()
+	}
+	return t
+}
+
+// formatOptional formats t, or returns nil if t is zero
+func (format TimeFormat) formatOptional(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return format.format(t)
+}
+
+// Config of the API middleware
+type Config struct {
+
+	// Root file system to serve information of
+	Root http.FileSystem
+
+	// TimeFormat of MTime in JSON. Defaults to TimeRFC3339Nano.
+	TimeFormat TimeFormat
+
+	// Logger of the middleware. Logs are discarded if nil.
+	Logger *slog.Logger
+
+	// Timeout of each endpoint request. No timeout if zero.
+	Timeout time.Duration
+
+	// EndpointTimeouts override Timeout by endpoint name, such as a
+	// longer one for "archive". Zero disables the timeout of the endpoint.
+	EndpointTimeouts map[string]time.Duration
+
+	// CompressThreshold is the size, in bytes, above which responses
+	// are compressed if the client accepts. No compression if zero.
+	CompressThreshold int
+
+	// Encoders are additional content encodings of compressed
+	// responses by name, such as "br", preferred over the builtin
+	// gzip and deflate
+	Encoders map[string]Encoder
+
+	// CORS config of the API. Disabled by default.
+	CORS CORSConfig
+
+	// RateLimit of the requests by client IP. Disabled by default.
+	RateLimit RateLimitConfig
+
+	// HideDotfiles omits entries beginning with "." from listings,
+	// and reports them as not found in stats
+	HideDotfiles bool
+
+	// AllowedExtensions, if not empty, are the only file extensions
+	// exposed, such as ".txt", including "" for files without one.
+	// DeniedExtensions are never exposed, whether allowed or not.
+	// Files of extensions not exposed are omitted from listings and
+	// forbidden otherwise. Extensions are matched case-insensitively.
+	AllowedExtensions []string
+	DeniedExtensions  []string
+
+	// NoFollowSymlinks reports symbolic links themselves in stats,
+	// instead of their destination. Only supported for http.Dir root.
+	NoFollowSymlinks bool
+
+	// AbsolutePaths displays the path and parent of stats as absolute
+	// paths of the host rather than relative to the root. Only supported
+	// for http.Dir root. This reveals the layout of the host file system
+	// to every client, so only enable it for trusted admin clients.
+	AbsolutePaths bool
+
+	// EntryErrors opens each entry of listings, reporting those that
+	// cannot be opened, such as broken or looping symlinks and files
+	// of denied permission, as StatError in place of their stats
+	EntryErrors bool
+
+	// Metrics collects metrics of the endpoints, if not nil
+	Metrics *Metrics
+
+	// Envelope wraps successful responses as {"status":"ok","data":...},
+	// consistent with the status of error responses
+	Envelope bool
+
+	// MaxEntries caps the number of entries of a listing. Listings
+	// exceeding it are truncated, to be continued by pagination.
+	// No cap if zero.
+	MaxEntries int
+
+	// StrongETag computes strong entity tags from the content hash
+	// of files for the stats and read endpoints, instead of weak
+	// ones from size and modification time. Expensive for large files.
+	StrongETag bool
+
+	// Writable enables the endpoints modifying the root, such as move.
+	// Only supported for http.Dir root. Disabled by default.
+	Writable bool
+
+	// DirMode is the permission of directories created by the
+	// mkdir endpoint. Defaults to 0755.
+	DirMode os.FileMode
+
+	// MaxUploadSize is the maximum size, in bytes, of the body of an
+	// upload. No maximum if zero.
+	MaxUploadSize int64
+
+	// MaxBodySize is the maximum size, in bytes, of the body of other
+	// requests, such as those of move, batch stats and GraphQL.
+	// Defaults to 1 MiB.
+	MaxBodySize int64
+
+	// MaxSearchResults bounds the entries returned by the search
+	// endpoint. Defaults to 1000.
+	MaxSearchResults int
+
+	// MaxArchiveBuffer is the maximum size, in bytes, of an archive
+	// buffered in a temporary file of os.TempDir to be served with a
+	// strong ETag and ranges, for requests of a range or conditional on
+	// an ETag. Other and larger archives are streamed without. Each
+	// such request uses up to this much disk space. Defaults to 64 MiB.
+	MaxArchiveBuffer int64
+
+	// EntryCountBound bounds the entries counted by the stats of a
+	// directory, unless the full count is requested. Defaults to 1000.
+	EntryCountBound int
+
+	// MaxDepth bounds the depth of the tree endpoint, whatever the
+	// depth requested. Defaults to 64.
+	MaxDepth int
+
+	// MaxExpensive bounds the concurrent requests of expensive
+	// operations: tree, archive, search, checksums and recursive
+	// usage. Requests over the limit are service unavailable. No
+	// limit if zero.
+	MaxExpensive int
+
+	// Shutdown coordinates the graceful shutdown of expensive
+	// requests, if not nil
+	Shutdown *Shutdown
+
+	// Authorize, if not nil, is called before serving any request of
+	// the API. A request it returns an error for is not served, but
+	// unauthorized if the error is ErrUnauthenticated and else forbidden,
+	// unless the error is a StatError of either code.
+	Authorize func(r *http.Request) error
+
+	// AuthChallenge is the WWW-Authenticate challenge of the requests
+	// unauthorized by Authorize. Defaults to Bearer.
+	AuthChallenge string
+
+	// ChecksumCacheSize is the number of checksums memoized by path,
+	// size and modification time, least recently used evicted first.
+	// No caching if zero.
+	ChecksumCacheSize int
+
+	// CaseInsensitive retries a case-insensitive match of the name
+	// within its parent directory if the exact path is not found
+	CaseInsensitive bool
+
+	// IndexFiles are the names of index files, in order of preference.
+	// The stats of a directory negotiated at its URL are those of its
+	// index file, if it has one.
+	IndexFiles []string
+
+	// RetryAfter is the delay advertised by the Retry-After header of
+	// service unavailable and timed out responses. Defaults to 5 seconds.
+	RetryAfter time.Duration
+
+	// CacheControl is the Cache-Control header of successful stats and
+	// listings, such as "max-age=60". Error responses are then
+	// "no-store". No Cache-Control header if empty.
+	CacheControl string
+
+	// AccessLog logs each endpoint request at info level, with its
+	// method, path, status, bytes, duration and request ID
+	AccessLog bool
+
+	// LogResponses logs the full response of each endpoint request at
+	// info level. Meant for debugging only, as responses can be large.
+	LogResponses bool
+
+	// JSONP wraps JSON responses in the function named by the callback
+	// query parameter, for legacy browser clients. Disabled by default.
+	JSONP bool
+
+	// BasePath is the client-facing path prefix of the API behind a
+	// reverse proxy that strips it, such as "/files". It prefixes the
+	// redirects and links generated.
+	BasePath string
+
+	// TrustForwardedPrefix takes the base path from the
+	// X-Forwarded-Prefix header of the proxy, if set, over BasePath.
+	// Only enable if the proxy sets or strips the header.
+	TrustForwardedPrefix bool
+
+	// absRoot is the host absolute path of the root, if paths
+	// are displayed absolute
+	absRoot string
+
+	// checksums memoized, if cached
+	checksums *checksumCache
+
+	// limiter of the requests by client, if rate limited
+	limiter *rateLimiter
+
+	// endpoints registered in addition to the built-in ones
+	endpoints []customEndpoint
+
+	// mount is the path the API is served at
+	mount string
+}
+
+// customEndpoint is an endpoint registered to the config
+type customEndpoint struct {
+	name     string
+	endpoint EndpointFunc
+}
+
+// RegisterEndpoint registers the endpoint at name under the API base.
+// It serves GET and HEAD requests, with the path after the name as
+// request, and the error handling of the built-in endpoints. Replaces
+// any built-in endpoint of the same name.
+func (cfg *Config) RegisterEndpoint(name string, endpoint EndpointFunc) {
+	cfg.endpoints = append(cfg.endpoints, customEndpoint{
+		name:     name,
+		endpoint: endpoint,
+	})
+}
+
+// logger returns the configured logger, or a no-op one
+func (cfg *Config) logger() *slog.Logger {
+	if cfg.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return cfg.Logger
+}
+
+// envelope wraps the successful response resp if configured
+func (cfg *Config) envelope(resp interface{}) interface{} {
+	if !cfg.Envelope {
+		return resp
+	}
+	return struct {
+		Status string      `json:"status"`
+		Data   interface{} `json:"data"`
+	}{
+		Status: "ok",
+		Data:   resp,
+	}
+}
+
+// dirMode returns the configured mode of created directories
+func (cfg *Config) dirMode() os.FileMode {
+	if cfg.DirMode == 0 {
+		return 0755
+	}
+	return cfg.DirMode
+}
+
+// maxBodySize returns the configured maximum size of request bodies
+func (cfg *Config) maxBodySize() int64 {
+	if cfg.MaxBodySize <= 0 {
+		return 1 << 20
+	}
+	return cfg.MaxBodySize
+}
+
+// timeout returns the timeout of requests of the named endpoint
+func (cfg *Config) timeout(endpoint string) time.Duration {
+	if timeout, ok := cfg.EndpointTimeouts[endpoint]; ok {
+		return timeout
+	}
+	return cfg.Timeout
+}
+
+// absoluteRoot returns the host absolute path of the http.Dir root
+// if AbsolutePaths is configured, or else an empty string
+func (cfg *Config) absoluteRoot() string {
+	dir, ok := cfg.Root.(http.Dir)
+	if !cfg.AbsolutePaths || !ok {
+		return ""
+	}
+	root := string(dir)
+	if root == "" {
+		root = "."
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return ""
+	}
+	return abs
+}
+
+// extensionDenied reports if files of the name are not exposed by
+// the allowed and denied extensions
+func (cfg *Config) extensionDenied(name string) bool {
+	if cfg.deniedExtension(name) {
+		return true
+	}
+	if len(cfg.AllowedExtensions) == 0 {
+		return false
+	}
+	ext := filepath.Ext(name)
+	for _, allowed := range cfg.AllowedExtensions {
+		if strings.EqualFold(ext, allowed) {
+			return false
+		}
+	}
+	return true
+}
+
+// deniedExtension reports if the name is of a denied extension
+func (cfg *Config) deniedExtension(name string) bool {
+	ext := filepath.Ext(name)
+	for _, denied := range cfg.DeniedExtensions {
+		if strings.EqualFold(ext, denied) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDenied reports if the path written is of an extension not
+// exposed. Directories, listed whatever their extension, are only
+// denied if named with a denied extension.
+func (cfg *Config) writeDenied(name string, dir bool) bool {
+	if dir {
+		return cfg.deniedExtension(name)
+	}
+	return cfg.extensionDenied(name)
+}
+
+// hiddenEntry reports if the directory entry is omitted from
+// listings, as dotfile or file of an extension not exposed
+func (cfg *Config) hiddenEntry(item os.FileInfo) bool {
+	if cfg.HideDotfiles && isHidden(item.Name()) {
+		return true
+	}
+	return !item.IsDir() && cfg.extensionDenied(item.Name())
+}
+
+// entryCountBound returns the configured bound of entries counted
+func (cfg *Config) entryCountBound() int {
+	if cfg.EntryCountBound <= 0 {
+		return 1000
+	}
+	return cfg.EntryCountBound
+}
+
+// maxArchiveBuffer returns the configured maximum size of
+// archives buffered
+func (cfg *Config) maxArchiveBuffer() int64 {
+	if cfg.MaxArchiveBuffer <= 0 {
+		return 64 << 20
+	}
+	return cfg.MaxArchiveBuffer
+}
+
+// maxDepth returns the configured maximum depth of trees
+func (cfg *Config) maxDepth() int {
+	if cfg.MaxDepth <= 0 {
+		return 64
+	}
+	return cfg.MaxDepth
+}
+
+// basePath returns the client-facing path prefix of the request,
+// without trailing slash. A forwarded prefix is only trusted if it is
+// an absolute path, so that redirects never lead to another host.
+func (cfg *Config) basePath(r *http.Request) string {
+	base := cfg.BasePath
+	if prefix := r.Header.Get("X-Forwarded-Prefix"); cfg.TrustForwardedPrefix && prefix != "" {
+		if !strings.HasPrefix(prefix, "/") || strings.HasPrefix(prefix, "//") || strings.ContainsAny(prefix, "\\?#") {
+			prefix = ""
+		}
+		base = prefix
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// setRetryAfter sets the Retry-After header of the response, in
+// whole seconds, rounded up
+func (cfg *Config) setRetryAfter(w http.ResponseWriter) {
+	delay := cfg.RetryAfter
+	if delay <= 0 {
+		delay = 5 * time.Second
+	}
+	seconds := int64((delay + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
+
+// hasEndpoint reports if a custom endpoint is registered at name
+func (cfg *Config) hasEndpoint(name string) bool {
+	for _, custom := range cfg.endpoints {
+		if custom.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Option configures the API middleware
+type Option func(*Config)
+
+// WithTimeFormat sets the serialization format of MTime
+func WithTimeFormat(format TimeFormat) Option {
+	return func(cfg *Config) {
+		cfg.TimeFormat = format
+	}
+}
+
+// WithLogger sets the logger of the middleware
+func WithLogger(logger *slog.Logger) Option {
+	return func(cfg *Config) {
+		cfg.Logger = logger
+	}
+}
+
+// WithTimeout sets the timeout of each endpoint request
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.Timeout = timeout
+	}
+}
+
+// WithEndpointTimeout sets the timeout of requests of the endpoint
+// of the given name, overriding the global one
+func WithEndpointTimeout(name string, timeout time.Duration) Option {
+	return func(cfg *Config) {
+		if cfg.EndpointTimeouts == nil {
+			cfg.EndpointTimeouts = make(map[string]time.Duration)
+		}
+		cfg.EndpointTimeouts[name] = timeout
+	}
+}
+
+// WithCompression enables compression of responses larger than
+// the threshold, in bytes
+func WithCompression(threshold int) Option {
+	return func(cfg *Config) {
+		cfg.CompressThreshold = threshold
+	}
+}
+
+// WithEncoder adds the content encoding of the given name to compress
+// responses, with compression enabled, such as Brotli:
+//
+//	api.WithEncoder("br", func(w io.Writer) io.WriteCloser {
+//		return brotli.NewWriter(w)
+//	})
+func WithEncoder(name string, encoder Encoder) Option {
+	return func(cfg *Config) {
+		if cfg.Encoders == nil {
+			cfg.Encoders = make(map[string]Encoder)
+		}
+		cfg.Encoders[strings.ToLower(name)] = encoder
+	}
+}
+
+// WithCORS sets the cross-origin resource sharing config
+func WithCORS(cors CORSConfig) Option {
+	return func(cfg *Config) {
+		cfg.CORS = cors
+	}
+}
+
+// WithRateLimit sets the rate limit of the requests by client IP
+func WithRateLimit(limit RateLimitConfig) Option {
+	return func(cfg *Config) {
+		cfg.RateLimit = limit
+	}
+}
+
+// WithAllowedExtensions sets the only file extensions exposed
+func WithAllowedExtensions(extensions ...string) Option {
+	return func(cfg *Config) {
+		cfg.AllowedExtensions = extensions
+	}
+}
+
+// WithDeniedExtensions sets the file extensions never exposed
+func WithDeniedExtensions(extensions ...string) Option {
+	return func(cfg *Config) {
+		cfg.DeniedExtensions = extensions
+	}
+}
+
+// WithHideDotfiles sets if dotfiles are hidden from the API
+func WithHideDotfiles(hide bool) Option {
+	return func(cfg *Config) {
+		cfg.HideDotfiles = hide
+	}
+}
+
+// WithNoFollowSymlinks sets if stats report symbolic links themselves
+func WithNoFollowSymlinks(noFollow bool) Option {
+	return func(cfg *Config) {
+		cfg.NoFollowSymlinks = noFollow
+	}
+}
+
+// WithAbsolutePaths sets if stats display host absolute paths
+func WithAbsolutePaths(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.AbsolutePaths = enabled
+	}
+}
+
+// WithEntryErrors sets if listings report the entries that cannot be
+// opened as errors
+func WithEntryErrors(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.EntryErrors = enabled
+	}
+}
+
+// WithMetrics records metrics of the endpoints to m
+func WithMetrics(m *Metrics) Option {
+	return func(cfg *Config) {
+		cfg.Metrics = m
+	}
+}
+
+// WithEnvelope sets if successful responses are wrapped in an envelope
+func WithEnvelope(envelope bool) Option {
+	return func(cfg *Config) {
+		cfg.Envelope = envelope
+	}
+}
+
+// WithEndpoint registers a custom endpoint, as Config.RegisterEndpoint
+func WithEndpoint(name string, endpoint EndpointFunc) Option {
+	return func(cfg *Config) {
+		cfg.RegisterEndpoint(name, endpoint)
+	}
+}
+
+// WithMaxEntries caps the number of entries of a listing
+func WithMaxEntries(max int) Option {
+	return func(cfg *Config) {
+		cfg.MaxEntries = max
+	}
+}
+
+// WithStrongETag sets if entity tags of files are computed from content
+func WithStrongETag(strong bool) Option {
+	return func(cfg *Config) {
+		cfg.StrongETag = strong
+	}
+}
+
+// WithWritable sets if the endpoints modifying the root are enabled
+func WithWritable(writable bool) Option {
+	return func(cfg *Config) {
+		cfg.Writable = writable
+	}
+}
+
+// WithDirMode sets the permission of directories created by the API
+func WithDirMode(mode os.FileMode) Option {
+	return func(cfg *Config) {
+		cfg.DirMode = mode
+	}
+}
+
+// WithMaxUploadSize sets the maximum size of uploads, in bytes
+func WithMaxUploadSize(size int64) Option {
+	return func(cfg *Config) {
+		cfg.MaxUploadSize = size
+	}
+}
+
+// WithMaxSearchResults bounds the entries returned by a search
+func WithMaxSearchResults(max int) Option {
+	return func(cfg *Config) {
+		cfg.MaxSearchResults = max
+	}
+}
+
+// WithMaxBodySize sets the maximum size of request bodies, other than
+// uploads, in bytes
+func WithMaxBodySize(size int64) Option {
+	return func(cfg *Config) {
+		cfg.MaxBodySize = size
+	}
+}
+
+// WithMaxArchiveBuffer sets the maximum size of archives buffered to be
+// served with a strong ETag and ranges. Each range or conditional
+// request of an archive writes up to size bytes to a temporary file of
+// os.TempDir, removed once served.
+func WithMaxArchiveBuffer(size int64) Option {
+	return func(cfg *Config) {
+		cfg.MaxArchiveBuffer = size
+	}
+}
+
+// WithMaxDepth sets the maximum depth of trees
+func WithMaxDepth(depth int) Option {
+	return func(cfg *Config) {
+		cfg.MaxDepth = depth
+	}
+}
+
+// WithEntryCountBound sets the bound of entries counted by the
+// stats of a directory
+func WithEntryCountBound(bound int) Option {
+	return func(cfg *Config) {
+		cfg.EntryCountBound = bound
+	}
+}
+
+// WithMaxExpensive sets the maximum of concurrent expensive requests
+func WithMaxExpensive(limit int) Option {
+	return func(cfg *Config) {
+		cfg.MaxExpensive = limit
+	}
+}
+
+// WithShutdown coordinates the graceful shutdown of
+// expensive requests with s
+func WithShutdown(s *Shutdown) Option {
+	return func(cfg *Config) {
+		cfg.Shutdown = s
+	}
+}
+
+// WithAuthorize sets the hook authorizing requests of the API
+func WithAuthorize(authorize func(r *http.Request) error) Option {
+	return func(cfg *Config) {
+		cfg.Authorize = authorize
+	}
+}
+
+// WithAuthChallenge sets the WWW-Authenticate challenge of the
+// requests unauthorized, such as `Basic realm="files"`
+func WithAuthChallenge(challenge string) Option {
+	return func(cfg *Config) {
+		cfg.AuthChallenge = challenge
+	}
+}
+
+// WithChecksumCache sets the number of checksums memoized
+func WithChecksumCache(size int) Option {
+	return func(cfg *Config) {
+		cfg.ChecksumCacheSize = size
+	}
+}
+
+// WithJSONP sets if JSON responses are wrapped in the callback query
+// parameter as JSONP
+func WithJSONP(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.JSONP = enabled
+	}
+}
+
+// WithBasePath sets the client-facing path prefix of the API behind a
+// reverse proxy
+func WithBasePath(base string) Option {
+	return func(cfg *Config) {
+		cfg.BasePath = base
+	}
+}
+
+// WithForwardedPrefix sets if the X-Forwarded-Prefix header of the
+// reverse proxy is trusted as client-facing path prefix
+func WithForwardedPrefix(trust bool) Option {
+	return func(cfg *Config) {
+		cfg.TrustForwardedPrefix = trust
+	}
+}
+
+// WithCaseInsensitive sets if names are matched case-insensitively
+// when the exact path is not found
+func WithCaseInsensitive(insensitive bool) Option {
+	return func(cfg *Config) {
+		cfg.CaseInsensitive = insensitive
+	}
+}
+
+// WithIndexFiles sets the names of index files to stat in place of
+// directories negotiated at their URL
+func WithIndexFiles(names ...string) Option {
+	return func(cfg *Config) {
+		cfg.IndexFiles = names
+	}
+}
+
+// WithRetryAfter sets the delay advertised to retry service
+// unavailable and timed out responses
+func WithRetryAfter(delay time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.RetryAfter = delay
+	}
+}
+
+// WithCacheControl sets the Cache-Control header of successful responses
+func WithCacheControl(directives string) Option {
+	return func(cfg *Config) {
+		cfg.CacheControl = directives
+	}
+}
+
+// WithAccessLog sets if endpoint requests are logged
+func WithAccessLog(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.AccessLog = enabled
+	}
+}
+
+// WithResponseLog sets if endpoint responses are logged in full
+func WithResponseLog(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.LogResponses = enabled
+	}
+}
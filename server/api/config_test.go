@@ -0,0 +1,501 @@
+package api_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestWithTimeFormat(t *testing.T) {
+
+	root := t.TempDir()
+	mtime := time.Date(2017, 8, 4, 10, 20, 30, 123456789, time.UTC)
+	file := filepath.Join(root, "file.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		desc   string
+		opts   []api.Option
+		target string
+		mtime  interface{}
+	}{
+		{"default", nil, "/api/stats/file.txt", nil},
+		{"RFC3339", []api.Option{api.WithTimeFormat(api.TimeRFC3339)}, "/api/stats/file.txt", "2017-08-04T10:20:30Z"},
+		{"RFC1123", []api.Option{api.WithTimeFormat(api.TimeRFC1123)}, "/api/stats/file.txt", "Fri, 04 Aug 2017 10:20:30 UTC"},
+		{"Unix", []api.Option{api.WithTimeFormat(api.TimeUnix)}, "/api/stats/file.txt", float64(mtime.Unix())},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		body := decodeBody(t, serveRequest(http.Dir(root), req, test.opts...))
+		have := body["mtime"]
+
+		// default format is in local time zone of the host
+		if test.desc == "default" {
+			parsed, err := time.Parse(time.RFC3339Nano, have.(string))
+			if err != nil || !parsed.Equal(mtime) {
+				t.Errorf("%s: expected mtime %v, got %#v", test.desc, mtime, have)
+			}
+			continue
+		}
+		if want := test.mtime; want != have {
+			t.Errorf("%s: expected mtime %#v, got %#v", test.desc, want, have)
+		}
+	}
+}
+
+func TestWithTimeFormat_directory(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/stats/folder", nil)
+	body := decodeBody(t, serveRequest(http.Dir(testRoot), req, api.WithTimeFormat(api.TimeUnix)))
+	if _, ok := body["mtime"].(float64); !ok {
+		t.Errorf("expected mtime to be a number, got %#v", body["mtime"])
+	}
+}
+
+func TestServeAPIWithConfig(t *testing.T) {
+
+	mw := api.ServeAPIWithConfig("/custom/", api.Config{
+		Root:       http.Dir(testRoot),
+		TimeFormat: api.TimeUnix,
+	})
+
+	w := httptest.NewRecorder()
+	mw(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/custom/stats/hello.txt", nil))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	body := decodeBody(t, w)
+	if want, have := "hello.txt", body["name"]; want != have {
+		t.Errorf("expected name %#v, got %#v", want, have)
+	}
+	if _, ok := body["mtime"].(float64); !ok {
+		t.Errorf("expected mtime to be a number, got %#v", body["mtime"])
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	serveRequest(http.Dir(testRoot), req, api.WithLogger(logger))
+
+	logs := buf.String()
+	if !strings.Contains(logs, "level=DEBUG") || !strings.Contains(logs, `msg="endpoint response"`) {
+		t.Errorf("expected debug log of the response, got %#v", logs)
+	}
+	if want, have := 1, strings.Count(logs, "\n"); want != have {
+		t.Errorf("expected %d log line, got %d: %#v", want, have, logs)
+	}
+	if strings.Contains(logs, "api.FileStat{") {
+		t.Errorf("expected response struct not to be dumped, got %#v", logs)
+	}
+
+	// nothing logged at info level
+	buf.Reset()
+	logger = slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	serveRequest(http.Dir(testRoot), req, api.WithLogger(logger))
+	if want, have := "", buf.String(); want != have {
+		t.Errorf("expected no log at info level, got %#v", have)
+	}
+}
+
+func TestHandleEndpoint_cancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil).WithContext(ctx)
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := api.StatusClientClosedRequest, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	body := decodeBody(t, w)
+	if want, have := "Client Closed Request", body["message"]; want != have {
+		t.Errorf("expected message %#v, got %#v", want, have)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	// the request context already exceeded its deadline
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil).WithContext(ctx)
+	w := serveRequest(http.Dir(testRoot), req, api.WithTimeout(time.Minute))
+	if want, have := http.StatusGatewayTimeout, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// the timeout configured is generous enough
+	req = httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	w = serveRequest(http.Dir(testRoot), req, api.WithTimeout(time.Minute))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// the timeout configured expires immediately
+	req = httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	w = serveRequest(http.Dir(testRoot), req, api.WithTimeout(time.Nanosecond))
+	if want, have := http.StatusGatewayTimeout, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestWithEndpointTimeout(t *testing.T) {
+
+	opts := []api.Option{
+		api.WithTimeout(time.Nanosecond),
+		api.WithEndpointTimeout("archive", time.Minute),
+	}
+
+	// the global timeout expires immediately
+	w := serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/stats/hello.txt", nil), opts...)
+	if want, have := http.StatusGatewayTimeout, w.Code; want != have {
+		t.Errorf("expected status %d of stats, got %d", want, have)
+	}
+	req := httptest.NewRequest("GET", "/?recursive=true", nil)
+	req.Header.Set("Accept", "application/goserve+json")
+	w = serveRequest(http.Dir(testRoot), req, opts...)
+	if want, have := http.StatusGatewayTimeout, w.Code; want != have {
+		t.Errorf("expected status %d of stats at the URL, got %d", want, have)
+	}
+	w = serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/archive/", nil), opts[0])
+	if want, have := http.StatusGatewayTimeout, w.Code; want != have {
+		t.Errorf("expected status %d of archive without override, got %d", want, have)
+	}
+
+	// the timeout of the archive endpoint is generous enough
+	w = serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/archive/", nil), opts...)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d of archive, got %d", want, have)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("error reading zip: %s", err)
+	}
+	if want, have := 3, len(zr.File); want != have {
+		t.Errorf("expected %d archived entries, got %d", want, have)
+	}
+}
+
+func TestWithHideDotfiles(t *testing.T) {
+
+	root := t.TempDir()
+	for _, name := range []string{".env", "visible.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		opts    []api.Option
+		names   string
+		envCode int
+	}{
+		{"default", nil, ".env, visible.txt", http.StatusOK},
+		{"visible", []api.Option{api.WithHideDotfiles(false)}, ".env, visible.txt", http.StatusOK},
+		{"hidden", []api.Option{api.WithHideDotfiles(true)}, "visible.txt", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/api/list/?sort=name", nil)
+		names := []string{}
+		for _, entry := range entriesOf(t, decodeBody(t, serveRequest(http.Dir(root), req, test.opts...))) {
+			names = append(names, entry.(map[string]interface{})["name"].(string))
+		}
+		if want, have := test.names, strings.Join(names, ", "); want != have {
+			t.Errorf("%s: expected entries %#v, got %#v", test.desc, want, have)
+		}
+
+		req = httptest.NewRequest("GET", "/api/stats/.env", nil)
+		if want, have := test.envCode, serveRequest(http.Dir(root), req, test.opts...).Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.desc, want, have)
+		}
+	}
+}
+
+func TestWithEnvelope(t *testing.T) {
+
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	body := decodeBody(t, serveRequest(http.Dir(testRoot), req, api.WithEnvelope(true)))
+	if want, have := "ok", body["status"]; want != have {
+		t.Errorf("expected status %#v, got %#v", want, have)
+	}
+	data, ok := body["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected data object, got %#v", body["data"])
+	}
+	if want, have := "hello.txt", data["name"]; want != have {
+		t.Errorf("expected data name %#v, got %#v", want, have)
+	}
+
+	// bare response by default
+	body = decodeBody(t, serveAPI(http.Dir(testRoot), "/api/stats/hello.txt"))
+	if want, have := "hello.txt", body["name"]; want != have {
+		t.Errorf("expected name %#v, got %#v", want, have)
+	}
+	if _, ok := body["data"]; ok {
+		t.Errorf("expected no envelope by default, got %#v", body)
+	}
+}
+
+func TestWithRetryAfter(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		target string
+		root   http.FileSystem
+		opts   []api.Option
+		code   int
+		want   string
+	}{
+		{"timeout default", "/api/stats/hello.txt", http.Dir(testRoot), []api.Option{api.WithTimeout(time.Nanosecond)}, http.StatusGatewayTimeout, "5"},
+		{"timeout configured", "/api/stats/hello.txt", http.Dir(testRoot), []api.Option{api.WithTimeout(time.Nanosecond), api.WithRetryAfter(1500 * time.Millisecond)}, http.StatusGatewayTimeout, "2"},
+		{"unavailable root", "/api/health", http.Dir(filepath.Join(t.TempDir(), "missing")), []api.Option{api.WithRetryAfter(30 * time.Second)}, http.StatusServiceUnavailable, "30"},
+	}
+
+	for _, test := range tests {
+		w := serveRequest(test.root, httptest.NewRequest("GET", test.target, nil), test.opts...)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+		}
+		if want, have := test.want, w.Header().Get("Retry-After"); want != have {
+			t.Errorf("%s: expected Retry-After %#v, got %#v", test.name, want, have)
+		}
+	}
+
+	// not on other responses
+	w := serveAPI(http.Dir(testRoot), "/api/stats/not-found")
+	if have := w.Header().Get("Retry-After"); have != "" {
+		t.Errorf("expected no Retry-After, got %#v", have)
+	}
+}
+
+func TestWithAccessLog(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	req.Header.Set("X-Request-ID", "request-1234")
+	w := serveRequest(http.Dir(testRoot), req, api.WithLogger(logger), api.WithAccessLog(true))
+	if want, have := "request-1234", w.Header().Get("X-Request-ID"); want != have {
+		t.Errorf("expected X-Request-ID %#v, got %#v", want, have)
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("error decoding log %#v: %s", buf.String(), err)
+	}
+	expected := map[string]interface{}{
+		"msg":       "access",
+		"method":    "GET",
+		"path":      "/api/stats/hello.txt",
+		"status":    float64(http.StatusOK),
+		"bytes":     float64(w.Body.Len()),
+		"requestID": "request-1234",
+	}
+	for key, want := range expected {
+		if have := record[key]; want != have {
+			t.Errorf("expected %s %#v, got %#v", key, want, have)
+		}
+	}
+	if _, ok := record["duration"]; !ok {
+		t.Errorf("expected duration in %#v", record)
+	}
+
+	// request ID generated if not forwarded
+	buf.Reset()
+	req = httptest.NewRequest("GET", "/api/stats/not-found", nil)
+	w = serveRequest(http.Dir(testRoot), req, api.WithLogger(logger), api.WithAccessLog(true))
+	id := w.Header().Get("X-Request-ID")
+	if id == "" {
+		t.Errorf("expected generated X-Request-ID")
+	}
+	if !strings.Contains(buf.String(), id) || !strings.Contains(buf.String(), `"status":404`) {
+		t.Errorf("expected access log with request ID %#v, got %#v", id, buf.String())
+	}
+
+	// not logged by default
+	buf.Reset()
+	serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/stats/hello.txt", nil), api.WithLogger(logger))
+	if want, have := "", buf.String(); want != have {
+		t.Errorf("expected no access log by default, got %#v", have)
+	}
+}
+
+func TestWithResponseLog(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	// silent by default
+	serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/stats/hello.txt", nil), api.WithLogger(logger))
+	if want, have := "", buf.String(); want != have {
+		t.Errorf("expected no response log by default, got %#v", have)
+	}
+
+	w := serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/stats/hello.txt", nil), api.WithLogger(logger), api.WithResponseLog(true))
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("error decoding log %#v: %s", buf.String(), err)
+	}
+	if want, have := "endpoint response body", record["msg"]; want != have {
+		t.Errorf("expected msg %#v, got %#v", want, have)
+	}
+	if want, have := w.Body.String(), record["body"]; want != have {
+		t.Errorf("expected body %#v, got %#v", want, have)
+	}
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+
+	root := writableRoot(t)
+	padding := strings.Repeat(" ", 64)
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{"move", "/api/move", `{"from": "file.txt", "to": "moved.txt"}` + padding},
+		{"batch stats", "/api/stats/", `["file.txt", "dir"]` + padding},
+	}
+
+	for _, test := range tests {
+
+		// over the limit configured
+		req := httptest.NewRequest("POST", test.target, strings.NewReader(padding+test.body))
+		w := serveRequest(http.Dir(root), req, api.WithWritable(true), api.WithMaxBodySize(64))
+		if want, have := http.StatusRequestEntityTooLarge, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+		}
+
+		// within the default limit
+		req = httptest.NewRequest("POST", test.target, strings.NewReader(test.body))
+		w = serveRequest(http.Dir(root), req, api.WithWritable(true))
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d: %s", test.name, want, have, w.Body.String())
+		}
+	}
+}
+
+func TestWithForwardedPrefix(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		prefix string
+		opts   []api.Option
+		want   string
+	}{
+		{"trusted", "/files", []api.Option{api.WithForwardedPrefix(true)}, "/files/api/?foo=bar"},
+		{"trailing slash", "/files/", []api.Option{api.WithForwardedPrefix(true)}, "/files/api/?foo=bar"},
+		{"untrusted", "/files", nil, "/api/?foo=bar"},
+		{"base path", "", []api.Option{api.WithBasePath("/static")}, "/static/api/?foo=bar"},
+		{"forwarded over base path", "/files", []api.Option{api.WithBasePath("/static"), api.WithForwardedPrefix(true)}, "/files/api/?foo=bar"},
+		{"other host", "//evil.example", []api.Option{api.WithForwardedPrefix(true)}, "/api/?foo=bar"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/api?foo=bar", nil)
+		if test.prefix != "" {
+			req.Header.Set("X-Forwarded-Prefix", test.prefix)
+		}
+		w := serveRequest(http.Dir(testRoot), req, test.opts...)
+		if want, have := http.StatusMovedPermanently, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+		}
+		if want, have := test.want, w.Header().Get("Location"); want != have {
+			t.Errorf("%s: expected Location %#v, got %#v", test.name, want, have)
+		}
+	}
+
+	// links of HTML listings
+	req := httptest.NewRequest("GET", "/api/list/folder", nil)
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("X-Forwarded-Prefix", "/files")
+	w := serveRequest(http.Dir(testRoot), req, api.WithForwardedPrefix(true))
+	if body := w.Body.String(); !strings.Contains(body, `href="/files/api/list/"`) {
+		t.Errorf("expected parent link with prefix, got %s", body)
+	}
+}
+
+func TestWithExtensions(t *testing.T) {
+
+	root := t.TempDir()
+	for _, name := range []string{"notes.txt", "server.key", "cert.PEM", "README", "dir.key/inner.txt"} {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		desc  string
+		opts  []api.Option
+		names string
+		codes map[string]int
+	}{
+		{
+			desc:  "denied",
+			opts:  []api.Option{api.WithDeniedExtensions(".key", ".pem")},
+			names: "README,dir.key,notes.txt",
+			codes: map[string]int{"notes.txt": http.StatusOK, "README": http.StatusOK, "server.key": http.StatusForbidden, "cert.PEM": http.StatusForbidden, "dir.key/inner.txt": http.StatusOK},
+		},
+		{
+			desc:  "allowed",
+			opts:  []api.Option{api.WithAllowedExtensions(".txt")},
+			names: "dir.key,notes.txt",
+			codes: map[string]int{"notes.txt": http.StatusOK, "README": http.StatusForbidden, "server.key": http.StatusForbidden},
+		},
+		{
+			desc:  "denied over allowed",
+			opts:  []api.Option{api.WithAllowedExtensions(".txt", ".key"), api.WithDeniedExtensions(".key")},
+			names: "dir.key,notes.txt",
+			codes: map[string]int{"notes.txt": http.StatusOK, "server.key": http.StatusForbidden},
+		},
+	}
+	for _, test := range tests {
+		w := serveRequest(http.Dir(root), httptest.NewRequest("GET", "/api/list/?sort=name", nil), test.opts...)
+		if want, have := test.names, strings.Join(entryNames(t, decodeBody(t, w)), ","); want != have {
+			t.Errorf("%s: expected entries %#v, got %#v", test.desc, want, have)
+		}
+		for name, code := range test.codes {
+			for _, endpoint := range []string{"stats", "read"} {
+				target := "/api/" + endpoint + "/" + name
+				w := serveRequest(http.Dir(root), httptest.NewRequest("GET", target, nil), test.opts...)
+				if want, have := code, w.Code; want != have {
+					t.Errorf("%s: %s: expected status %d, got %d", test.desc, target, want, have)
+				}
+			}
+		}
+	}
+
+	// omitted from trees too
+	w := serveRequest(http.Dir(root), httptest.NewRequest("GET", "/api/tree/?sort=name", nil), api.WithDeniedExtensions(".key", ".pem"))
+	if want, have := "README,dir.key,notes.txt", strings.Join(entryNames(t, decodeBody(t, w)), ","); want != have {
+		t.Errorf("expected tree entries %#v, got %#v", want, have)
+	}
+}
@@ -12,6 +12,7 @@ const (
 	ctxKeyEndpointContext contextKey = iota
 	ctxKeyFS
 	ctxKeyGraphContext
+	ctxKeyConfig
 )
 
 type endpointContext struct {
@@ -19,6 +20,7 @@ type endpointContext struct {
 	Host   string
 	Scheme string
 	Query  url.Values
+	Header http.Header
 	FS     http.FileSystem
 }
 
@@ -32,6 +34,7 @@ func withEndpointContext(parent context.Context, r *http.Request) context.Contex
 		Host:   r.Host,
 		Scheme: scheme,
 		Query:  r.URL.Query(),
+		Header: r.Header,
 	}
 	return context.WithValue(parent, ctxKeyEndpointContext, epCtx)
 }
@@ -63,3 +66,14 @@ func getGraphContext(ctx context.Context) (graphCtx *graphContext) {
 	graphCtx, _ = ctx.Value(ctxKeyGraphContext).(*graphContext)
 	return
 }
+
+func withConfig(parent context.Context, cfg *Config) context.Context {
+	return context.WithValue(parent, ctxKeyConfig, cfg)
+}
+
+func getConfig(ctx context.Context) (cfg *Config) {
+	if cfg, _ = ctx.Value(ctxKeyConfig).(*Config); cfg == nil {
+		cfg = &Config{}
+	}
+	return
+}
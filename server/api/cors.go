@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CORSConfig is the cross-origin resource sharing config of the API
+type CORSConfig struct {
+
+	// AllowedOrigins of cross-origin requests, or "*" for any origin.
+	// CORS is disabled if empty.
+	AllowedOrigins []string
+
+	// AllowedMethods of cross-origin requests. Defaults to GET and HEAD.
+	AllowedMethods []string
+
+	// AllowedHeaders of cross-origin requests
+	AllowedHeaders []string
+}
+
+// allowOrigin reports if cross-origin requests from origin are allowed
+func (c CORSConfig) allowOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// handle writes the CORS headers of the request, if CORS is enabled and
+// the origin is allowed. Returns true if the request is a preflight
+// request and the response has been written.
+func (c CORSConfig) handle(w http.ResponseWriter, r *http.Request) bool {
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(c.AllowedOrigins) == 0 {
+		return false
+	}
+	w.Header().Add("Vary", "Origin")
+	if !c.allowOrigin(origin) {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+
+	// preflight request
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		methods := c.AllowedMethods
+		if len(methods) == 0 {
+			methods = []string{http.MethodGet, http.MethodHead}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(c.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+	return false
+}
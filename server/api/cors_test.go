@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestWithCORS_preflight(t *testing.T) {
+
+	cors := api.WithCORS(api.CORSConfig{
+		AllowedOrigins: []string{"http://example.com"},
+		AllowedHeaders: []string{"Content-Type"},
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/api/stats/hello.txt", nil)
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := serveRequest(http.Dir(testRoot), req, cors)
+
+	if want, have := http.StatusNoContent, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "http://example.com",
+		"Access-Control-Allow-Methods": "GET, HEAD",
+		"Access-Control-Allow-Headers": "Content-Type",
+	}
+	for key, want := range expected {
+		if have := w.Header().Get(key); want != have {
+			t.Errorf("expected %s %#v, got %#v", key, want, have)
+		}
+	}
+}
+
+func TestWithCORS_simple(t *testing.T) {
+
+	cors := api.WithCORS(api.CORSConfig{
+		AllowedOrigins: []string{"http://example.com"},
+	})
+
+	// allowed origin
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	req.Header.Set("Origin", "http://example.com")
+	w := serveRequest(http.Dir(testRoot), req, cors)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := "http://example.com", w.Header().Get("Access-Control-Allow-Origin"); want != have {
+		t.Errorf("expected Access-Control-Allow-Origin %#v, got %#v", want, have)
+	}
+
+	// other origin
+	req = httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	req.Header.Set("Origin", "http://other.com")
+	w = serveRequest(http.Dir(testRoot), req, cors)
+	if want, have := "", w.Header().Get("Access-Control-Allow-Origin"); want != have {
+		t.Errorf("expected Access-Control-Allow-Origin %#v, got %#v", want, have)
+	}
+}
+
+func TestWithCORS_disabled(t *testing.T) {
+	req := httptest.NewRequest("OPTIONS", "/api/stats/hello.txt", nil)
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := "", w.Header().Get("Access-Control-Allow-Origin"); want != have {
+		t.Errorf("expected Access-Control-Allow-Origin %#v, got %#v", want, have)
+	}
+	if w.Code == http.StatusNoContent {
+		t.Errorf("expected preflight not to be handled")
+	}
+}
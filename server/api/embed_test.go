@@ -0,0 +1,78 @@
+package api_test
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"testing"
+)
+
+//go:embed testdata/root
+var embedded embed.FS
+
+// embedRoot returns the test root embedded in the test binary
+func embedRoot(t *testing.T) http.FileSystem {
+	sub, err := fs.Sub(embedded, "testdata/root")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return http.FS(sub)
+}
+
+func TestServeAPI_embedFS(t *testing.T) {
+
+	root := embedRoot(t)
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/api/stats/hello.txt", http.StatusOK},
+		{"/api/stats/folder", http.StatusOK},
+		{"/api/stats/?recursive=true", http.StatusOK},
+		{"/api/stats/hello.txt?hash=sha256", http.StatusOK},
+		{"/api/stats/?paths=hello.txt,folder/nested.txt", http.StatusOK},
+		{"/api/list/", http.StatusOK},
+		{"/api/list/folder", http.StatusOK},
+		{"/api/tree/", http.StatusOK},
+		{"/api/read/hello.txt", http.StatusOK},
+		{"/api/exists/folder/nested.txt", http.StatusNoContent},
+		{"/api/exists/missing.txt", http.StatusNotFound},
+		{"/api/archive/folder", http.StatusOK},
+		{"/api/search/?q=nested", http.StatusOK},
+		{"/api/health", http.StatusOK},
+		{"/api/mkdir/new", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		w := serveAPI(root, test.target)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d: %s", test.target, want, have, w.Body.String())
+		}
+	}
+
+	body := decodeBody(t, serveAPI(root, "/api/stats/hello.txt"))
+	if want, have := float64(6), body["size"]; want != have {
+		t.Errorf("expected size %#v, got %#v", want, have)
+	}
+	if want, have := "file", body["type"]; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+
+	// embedded files have no modification time to validate
+	w := serveAPI(root, "/api/stats/hello.txt")
+	if have := w.Header().Get("Last-Modified"); have != "" {
+		t.Errorf("expected no Last-Modified, got %#v", have)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Errorf("expected ETag header")
+	}
+
+	list := decodeBody(t, serveAPI(root, "/api/list/"))
+	if want, have := 2, len(entriesOf(t, list)); want != have {
+		t.Errorf("expected %d entries, got %d", want, have)
+	}
+
+	w = serveAPI(root, "/api/read/folder/nested.txt")
+	if want, have := "nested\n", w.Body.String(); want != have {
+		t.Errorf("expected content %#v, got %#v", want, have)
+	}
+}
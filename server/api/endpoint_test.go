@@ -0,0 +1,59 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestRegisterEndpoint(t *testing.T) {
+
+	cfg := api.Config{Root: http.Dir(testRoot)}
+	cfg.RegisterEndpoint("echo", func(ctx context.Context, req interface{}) (interface{}, error) {
+		path := req.(string)
+		if path == "missing" {
+			return nil, api.NewStatError(http.StatusNotFound, path)
+		}
+		return map[string]string{"path": path}, nil
+	})
+	handler := api.ServeAPIWithConfig("/api", cfg)(http.NotFoundHandler())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/echo/some/path", nil))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := "some/path", decodeBody(t, w)["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+
+	// error handling of the built-in endpoints
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/echo/missing", nil))
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := "error", decodeBody(t, w)["status"]; want != have {
+		t.Errorf("expected status %#v, got %#v", want, have)
+	}
+
+	// built-in endpoints still served
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/stats/hello.txt", nil))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestWithEndpoint(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/ping", nil)
+	w := serveRequest(http.Dir(testRoot), req, api.WithEndpoint("ping", func(ctx context.Context, req interface{}) (interface{}, error) {
+		return map[string]string{"status": "pong"}, nil
+	}))
+	if want, have := "pong", decodeBody(t, w)["status"]; want != have {
+		t.Errorf("expected status %#v, got %#v", want, have)
+	}
+}
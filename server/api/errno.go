@@ -0,0 +1,18 @@
+//go:build !plan9
+
+package api
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isSymlinkLoop reports if err is of too many levels of symlinks
+func isSymlinkLoop(err error) bool {
+	return errors.Is(err, syscall.ELOOP)
+}
+
+// isCrossDevice reports if err is of a rename across devices
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
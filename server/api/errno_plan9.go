@@ -0,0 +1,13 @@
+//go:build plan9
+
+package api
+
+// isSymlinkLoop is not supported on this platform, without symlinks
+func isSymlinkLoop(err error) bool {
+	return false
+}
+
+// isCrossDevice is not supported on this platform
+func isCrossDevice(err error) bool {
+	return false
+}
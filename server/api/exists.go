@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+)
+
+// handleExists reports if the file or directory at the request path
+// exists by status code only: 204 if it exists, or the status of the
+// error otherwise (e.g. 404 or 403), without body
+func handleExists(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// only read methods are allowed
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := withFilesystem(withEndpointContext(r.Context(), r), cfg.Root)
+		ctx = withConfig(ctx, cfg)
+
+		path, err := scopePath(r.URL.Path)
+		if err == nil {
+			var file http.File
+			if file, _, err = openFile(ctx, path); err == nil {
+				file.Close()
+			}
+		}
+
+		switch serr := err.(type) {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case *StatError:
+			w.WriteHeader(serr.Code)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
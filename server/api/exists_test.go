@@ -0,0 +1,32 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExistsEndpoint(t *testing.T) {
+
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/api/exists/hello.txt", http.StatusNoContent},
+		{"/api/exists/folder", http.StatusNoContent},
+		{"/api/exists/not-found", http.StatusNotFound},
+		{"/api/exists/../secret", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		for _, method := range []string{"GET", "HEAD"} {
+			w := serveRequest(http.Dir(testRoot), httptest.NewRequest(method, test.target, nil))
+			if want, have := test.code, w.Code; want != have {
+				t.Errorf("%s %s: expected status %d, got %d", method, test.target, want, have)
+			}
+			if want, have := 0, w.Body.Len(); want != have {
+				t.Errorf("%s %s: expected no body, got %#v", method, test.target, w.Body.String())
+			}
+		}
+	}
+}
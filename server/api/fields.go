@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// fieldSelection restricts the JSON of Stat to the selected fields.
+// Field names unknown to the stats are ignored.
+type fieldSelection struct {
+	Stat   interface{}
+	Fields []string
+}
+
+// selectFields restricts the JSON of stats to a comma separated
+// list of fields
+func selectFields(stats interface{}, fields string) fieldSelection {
+	selection := fieldSelection{Stat: stats}
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			selection.Fields = append(selection.Fields, field)
+		}
+	}
+	return selection
+}
+
+// MarshalJSON implements encoding/json.Marshaler
+func (selection fieldSelection) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(selection.Stat)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err = json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	selected := make(map[string]json.RawMessage, len(selection.Fields))
+	for _, field := range selection.Fields {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return json.Marshal(selected)
+}
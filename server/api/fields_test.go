@@ -0,0 +1,43 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStatsEndpoint_fields(t *testing.T) {
+
+	tests := []struct {
+		target string
+		fields []string
+	}{
+		{"/api/stats/hello.txt?fields=name,size", []string{"name", "size"}},
+		{"/api/stats/hello.txt?fields=type", []string{"type"}},
+		{"/api/stats/hello.txt?fields=name,unknown", []string{"name"}},
+		{"/api/stats/folder?fields=name,size,entries", []string{"name"}},
+	}
+
+	for _, test := range tests {
+		w := serveAPI(http.Dir(testRoot), test.target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := len(test.fields), len(body); want != have {
+			t.Errorf("%s: expected %d fields, got %#v", test.target, want, body)
+		}
+		for _, field := range test.fields {
+			if _, ok := body[field]; !ok {
+				t.Errorf("%s: expected field %#v in %#v", test.target, field, body)
+			}
+		}
+	}
+}
+
+func TestStatsEndpoint_fieldsETag(t *testing.T) {
+	w := serveAPI(http.Dir(testRoot), "/api/stats/hello.txt?fields=name")
+	if w.Header().Get("ETag") == "" {
+		t.Errorf("expected ETag on response with selected fields")
+	}
+}
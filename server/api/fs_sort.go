@@ -43,6 +43,8 @@ func SortBy(by string, files []os.FileInfo) (s sort.Interface, err error) {
 		s = ByName(files)
 	case "mtime":
 		s = ByModTime(files)
+	case "size":
+		s = BySize(files)
 	case "type":
 		s = ByType(files)
 	default:
@@ -98,6 +100,27 @@ func (fi ByModTime) Swap(i, j int) {
 	fi[j] = tmp
 }
 
+// BySize sorts []os.FileInfo by the Size() results
+type BySize []os.FileInfo
+
+// Len is the number of elements in the collection.
+func (fi BySize) Len() int {
+	return len(fi)
+}
+
+// Less reports whether the element with
+// index i should sort before the element with index j.
+func (fi BySize) Less(i, j int) bool {
+	return fi[i].Size() < fi[j].Size()
+}
+
+// Swap swaps the elements with indexes i and j.
+func (fi BySize) Swap(i, j int) {
+	tmp := fi[i]
+	fi[i] = fi[j]
+	fi[j] = tmp
+}
+
 // ByType sorts directory before files in []os.FileInfo
 type ByType []os.FileInfo
 
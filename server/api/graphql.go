@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"mime"
 	"net/http"
 	"os"
@@ -21,22 +20,12 @@ func graphStatFile(ctx context.Context, filepath string) (resp *FileInfo, err er
 
 	fs := getFilesystem(ctx)
 
-	// replace os.Stat with FileSystem read
-	fsEntry, err := fs.Open(filepath)
-	if os.IsNotExist(err) {
-		err = NewStatError(http.StatusNotFound, filepath)
-		return
-	} else if err != nil {
-		err = newError(http.StatusBadRequest, err)
-		return
-	}
-	defer fsEntry.Close()
-
-	stat, err := fsEntry.Stat()
+	// hidden files and extensions not exposed as with stats
+	fsEntry, stat, err := openFile(ctx, filepath)
 	if err != nil {
-		err = newError(http.StatusBadRequest, err)
 		return
 	}
+	defer fsEntry.Close()
 
 	statType := "other"
 	mimeType := ""
@@ -80,54 +69,28 @@ func graphListFiles(ctx context.Context, filepath string) (list []*FileInfo, err
 	graphCtx := getGraphContext(ctx)
 	args := graphCtx.Args
 
-	// replace os.Stat with FileSystem read
-	fsEntry, err := fs.Open(filepath)
-	if os.IsNotExist(err) {
-		err = NewStatError(http.StatusNotFound, filepath)
-		return
-	} else if err != nil {
-		err = newError(http.StatusBadRequest, err)
-		return
-	}
-	defer fsEntry.Close()
-
-	stat, err := fsEntry.Stat()
+	// hidden files and extensions not exposed as with listings
+	d, stat, err := openFile(ctx, filepath)
 	if err != nil {
-		err = newError(http.StatusBadRequest, err)
-		return
-	}
-
-	// permission problem
-	if err != nil {
-		perr, _ := err.(*os.PathError)
-		if perr.Err.Error() == os.ErrPermission.Error() {
-			err = NewStatError(http.StatusForbidden, filepath)
-		}
 		return
 	}
+	defer d.Close()
 
 	// for directories
 	if stat.Mode().IsDir() {
 
-		var d http.File
-		files := make([]os.FileInfo, 0, 40)
-		if d, err = fs.Open(filepath); err != nil {
-			log.Printf("Error listing filepath %#v:%s", filepath, err)
-			err = NewStatError(http.StatusInternalServerError, filepath)
-			return
-		}
-		defer d.Close()
-
-		files, err = d.Readdir(0)
+		var files []os.FileInfo
+		files, err = readDir(d)
 		if err != nil {
-			log.Printf("Error listing filepath %#v:%s", filepath, err)
+			getConfig(ctx).logger().Error("error listing path", "path", filepath, "error", err)
 			return
 		}
 
-		listLen := len(files)
-		list = make([]*FileInfo, listLen)
-		for i := 0; i < listLen; i++ {
-			item := files[i]
+		list = make([]*FileInfo, 0, len(files))
+		for _, item := range files {
+			if getConfig(ctx).hiddenEntry(item) {
+				continue
+			}
 
 			// parse item URL
 			itemPath := filepath + "/" + item.Name()
@@ -144,7 +107,7 @@ func graphListFiles(ctx context.Context, filepath string) (list []*FileInfo, err
 				itemType = "directory"
 			}
 
-			list[i] = &FileInfo{
+			list = append(list, &FileInfo{
 				Name:     item.Name(),
 				Type:     itemType,
 				Mime:     mimeType,
@@ -152,7 +115,7 @@ func graphListFiles(ctx context.Context, filepath string) (list []*FileInfo, err
 				Path:     "/" + itemPath,
 				Size:     item.Size(),
 				MTime:    item.ModTime(),
-			}
+			})
 		}
 
 		s := "-mtime"
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+)
+
+// healthStatus is the JSON response of the health endpoint
+type healthStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// handleHealth reports if the root of the configured file system
+// is accessible, for liveness and readiness probes
+func handleHealth(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// only read methods are allowed
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		root, err := cfg.Root.Open("/")
+		if err == nil {
+			_, err = root.Stat()
+			root.Close()
+		}
+		if err != nil {
+			cfg.logger().Error("root is not accessible", "error", err)
+			cfg.setRetryAfter(w)
+			writeJSON(w, r, http.StatusServiceUnavailable, healthStatus{
+				Status:  "unavailable",
+				Message: "root is not accessible",
+			})
+			return
+		}
+		writeJSON(w, r, http.StatusOK, healthStatus{Status: "ok"})
+	}
+}
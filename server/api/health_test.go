@@ -0,0 +1,47 @@
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHealthEndpoint(t *testing.T) {
+	for _, target := range []string{"/api/health", "/api/health/"} {
+		w := serveAPI(http.Dir(testRoot), target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+			continue
+		}
+		if want, have := "ok", decodeBody(t, w)["status"]; want != have {
+			t.Errorf("%s: expected status %#v, got %#v", target, want, have)
+		}
+	}
+}
+
+func TestHealthEndpoint_unavailable(t *testing.T) {
+
+	tests := []struct {
+		name string
+		root http.FileSystem
+	}{
+		{"missing root", http.Dir(filepath.Join(t.TempDir(), "missing"))},
+		{"open error", errorFileSystem{err: os.ErrPermission}},
+	}
+
+	for _, test := range tests {
+		w := serveAPI(test.root, "/api/health")
+		if want, have := http.StatusServiceUnavailable, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := "unavailable", body["status"]; want != have {
+			t.Errorf("%s: expected status %#v, got %#v", test.name, want, have)
+		}
+		if _, ok := body["message"]; !ok {
+			t.Errorf("%s: expected message in %#v", test.name, body)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+// listingEntry is an entry of the HTML listing
+type listingEntry struct {
+	Name  string
+	Href  string
+	Type  string
+	Size  string
+	MTime string
+}
+
+// listingPage is the data of the HTML listing template
+type listingPage struct {
+	Path    string
+	Parent  string
+	Next    string
+	Entries []listingEntry
+}
+
+var tplListing = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of /{{.Path}}</title>
+</head>
+<body>
+<h1>Index of /{{.Path}}</h1>
+<table>
+<thead>
+<tr><th>Name</th><th>Type</th><th>Size</th><th>Modified</th></tr>
+</thead>
+<tbody>
+{{- if .Parent}}
+<tr><td><a href="{{.Parent}}">..</a></td><td>directory</td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td>{{.Type}}</td><td>{{.Size}}</td><td>{{.MTime}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- if .Next}}
+<p><a href="{{.Next}}">Next page</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+// apiHref is the URL of the endpoint for the root relative path, with
+// the API mounted at mount
+func apiHref(mount, endpoint, p string) string {
+	return (&url.URL{Path: mount + "/" + endpoint + "/" + p}).EscapedPath()
+}
+
+// renderListing writes the listing of the directory as HTML page of
+// entries linked to their listing, for directories, or content, for
+// files, in the API mounted at mount
+func renderListing(w io.Writer, mount string, dir DirStat) error {
+	page := listingPage{
+		Path:    dir.Path,
+		Entries: make([]listingEntry, 0, len(dir.Entries)),
+	}
+	if dir.Path != "" {
+		parent := path.Dir(dir.Path)
+		if parent == "." {
+			parent = ""
+		}
+		page.Parent = apiHref(mount, "list", parent)
+	}
+	if dir.Page != nil && dir.Page.Next != nil {
+		next := url.Values{}
+		next.Set("offset", strconv.Itoa(*dir.Page.Next))
+		if dir.Page.Limit > 0 {
+			next.Set("limit", strconv.Itoa(dir.Page.Limit))
+		}
+		page.Next = apiHref(mount, "list", dir.Path) + "?" + next.Encode()
+	}
+	for _, entry := range dir.Entries {
+		switch entry := entry.(type) {
+		case FileStat:
+			page.Entries = append(page.Entries, listingEntry{
+				Name:  entry.Name,
+				Href:  apiHref(mount, "read", entry.Path),
+				Type:  "file",
+				Size:  strconv.FormatInt(entry.Size, 10),
+				MTime: entry.MTime.UTC().Format(http.TimeFormat),
+			})
+		case DirStat:
+			page.Entries = append(page.Entries, listingEntry{
+				Name:  entry.Name + "/",
+				Href:  apiHref(mount, "list", entry.Path),
+				Type:  "directory",
+				MTime: entry.MTime.UTC().Format(http.TimeFormat),
+			})
+		case *StatError:
+			page.Entries = append(page.Entries, listingEntry{
+				Name: path.Base(entry.Path),
+				Href: apiHref(mount, "stats", entry.Path),
+				Type: "error: " + entry.Message(),
+			})
+		}
+	}
+	return tplListing.Execute(w, page)
+}
+
+var tplError = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Code}} {{.Message}}</title>
+</head>
+<body>
+<h1>{{.Code}} {{.Message}}</h1>
+{{- if .Path}}
+<p>{{.Path}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// renderError writes the error as HTML page
+func renderError(w io.Writer, code int, message, path string) error {
+	return tplError.Execute(w, struct {
+		Code    int
+		Message string
+		Path    string
+	}{code, message, path})
+}
@@ -0,0 +1,70 @@
+package api_test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListEndpoint_html(t *testing.T) {
+
+	req := httptest.NewRequest("GET", "/api/list/folder", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := "text/html; charset=utf-8", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+
+	// collect the links of the page, parsed as HTML
+	links := make(map[string]string)
+	dec := xml.NewDecoder(strings.NewReader(w.Body.String()))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	var href string
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("error parsing HTML %#v: %s", w.Body.String(), err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if token.Name.Local == "a" {
+				for _, attr := range token.Attr {
+					if attr.Name.Local == "href" {
+						href = attr.Value
+					}
+				}
+			}
+		case xml.CharData:
+			if href != "" {
+				links[string(token)] = href
+				href = ""
+			}
+		}
+	}
+
+	if want, have := "/api/read/folder/nested.txt", links["nested.txt"]; want != have {
+		t.Errorf("expected link of nested.txt %#v, got %#v", want, have)
+	}
+	if want, have := "/api/list/", links[".."]; want != have {
+		t.Errorf("expected parent link %#v, got %#v", want, have)
+	}
+}
+
+func TestListEndpoint_htmlNotPreferred(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/list/", nil)
+	req.Header.Set("Accept", "application/json, text/html;q=0.5")
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := "application/json", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+}
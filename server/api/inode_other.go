@@ -0,0 +1,10 @@
+//go:build !unix
+
+package api
+
+import "os"
+
+// fileInode is not supported on this platform
+func fileInode(stat os.FileInfo) (dev, ino uint64) {
+	return 0, 0
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package api
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the device and inode numbers of the file from its
+// syscall.Stat_t, or zeros if the file info does not provide them
+func fileInode(stat os.FileInfo) (dev, ino uint64) {
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return uint64(sys.Dev), uint64(sys.Ino)
+}
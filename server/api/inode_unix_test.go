@@ -0,0 +1,40 @@
+//go:build unix
+
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatsEndpoint_inode(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "other.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Link(filepath.Join(root, "file.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skipf("hard link not supported: %s", err)
+	}
+
+	file := decodeBody(t, serveAPI(http.Dir(root), "/api/stats/file.txt"))
+	link := decodeBody(t, serveAPI(http.Dir(root), "/api/stats/link.txt"))
+	other := decodeBody(t, serveAPI(http.Dir(root), "/api/stats/other.txt"))
+	if file["ino"] == nil {
+		t.Fatalf("expected ino, got none")
+	}
+	if want, have := file["ino"], link["ino"]; want != have {
+		t.Errorf("expected ino %#v of hard link, got %#v", want, have)
+	}
+	if want, have := file["dev"], link["dev"]; want != have {
+		t.Errorf("expected dev %#v of hard link, got %#v", want, have)
+	}
+	if file["ino"] == other["ino"] {
+		t.Errorf("expected distinct ino of other file, got %#v", other["ino"])
+	}
+}
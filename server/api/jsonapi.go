@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// jsonAPIMediaType is the media type of JSON:API documents
+const jsonAPIMediaType = "application/vnd.api+json"
+
+// jsonAPIResource is a JSON:API resource object
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]json.RawMessage     `json:"attributes"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+}
+
+// jsonAPIIdentifier identifies a JSON:API resource object
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// jsonAPIRelationship is a JSON:API relationship to many resources
+type jsonAPIRelationship struct {
+	Data []jsonAPIIdentifier `json:"data"`
+}
+
+// jsonAPIError is a JSON:API error object
+type jsonAPIError struct {
+	Status string            `json:"status"`
+	Title  string            `json:"title"`
+	Meta   map[string]string `json:"meta,omitempty"`
+}
+
+// jsonAPIDocument formats the response as JSON:API document. Stats
+// are resource objects identified by their path, with the entries of
+// directories as related and included resources. Other responses are
+// returned as meta of the document.
+func jsonAPIDocument(resp interface{}) (doc map[string]interface{}, err error) {
+	doc = make(map[string]interface{})
+	var included []jsonAPIResource
+
+	switch stats := resp.(type) {
+	case []interface{}:
+		data := make([]jsonAPIResource, 0, len(stats))
+		for _, stat := range stats {
+			var resource jsonAPIResource
+			if resource, err = jsonAPIResourceOf(stat, &included); err != nil {
+				return
+			}
+			data = append(data, resource)
+		}
+		doc["data"] = data
+	case FileStat, DirStat, fieldSelection:
+		var resource jsonAPIResource
+		if resource, err = jsonAPIResourceOf(stats, &included); err != nil {
+			return
+		}
+		doc["data"] = resource
+	default:
+		doc["meta"] = resp
+		return
+	}
+
+	if len(included) > 0 {
+		doc["included"] = included
+	}
+	return
+}
+
+// jsonAPIResourceOf formats the stat as resource object, and appends
+// the entries of directories, recursively, to included
+func jsonAPIResourceOf(stat interface{}, included *[]jsonAPIResource) (resource jsonAPIResource, err error) {
+
+	b, err := json.Marshal(stat)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &resource.Attributes); err != nil {
+		return
+	}
+
+	var entries []interface{}
+	switch s := stat.(type) {
+	case fieldSelection:
+		return jsonAPIResourceOfSelection(s, resource.Attributes)
+	case FileStat:
+		resource.Type, resource.ID = "file", "/"+s.Path
+	case DirStat:
+		resource.Type, resource.ID = "directory", "/"+s.Path
+		entries = s.Entries
+	case *StatError:
+		resource.Type, resource.ID = "error", "/"+s.Path
+	}
+	delete(resource.Attributes, "type")
+	delete(resource.Attributes, "entries")
+
+	if entries != nil {
+		related := jsonAPIRelationship{Data: make([]jsonAPIIdentifier, 0, len(entries))}
+		for _, entry := range entries {
+			var child jsonAPIResource
+			if child, err = jsonAPIResourceOf(entry, included); err != nil {
+				return
+			}
+			related.Data = append(related.Data, jsonAPIIdentifier{child.Type, child.ID})
+			*included = append(*included, child)
+		}
+		resource.Relationships = map[string]jsonAPIRelationship{"entries": related}
+	}
+	return
+}
+
+// jsonAPIResourceOfSelection formats the stat with selected fields as
+// resource object of the selected attributes
+func jsonAPIResourceOfSelection(selection fieldSelection, attributes map[string]json.RawMessage) (resource jsonAPIResource, err error) {
+	var none []jsonAPIResource
+	if resource, err = jsonAPIResourceOf(selection.Stat, &none); err != nil {
+		return
+	}
+	delete(attributes, "type")
+	delete(attributes, "entries")
+	resource.Attributes = attributes
+	resource.Relationships = nil
+	return
+}
+
+// writeJSONAPIError writes the error as JSON:API error document
+func writeJSONAPIError(w http.ResponseWriter, r *http.Request, code int, message, path string) {
+	apiErr := jsonAPIError{
+		Status: strconv.Itoa(code),
+		Title:  message,
+	}
+	if path != "" {
+		apiErr.Meta = map[string]string{"path": path}
+	}
+	w.Header().Set("Content-Type", jsonAPIMediaType)
+	writeJSON(w, r, code, struct {
+		Errors []jsonAPIError `json:"errors"`
+	}{
+		Errors: []jsonAPIError{apiErr},
+	})
+}
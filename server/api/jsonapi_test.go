@@ -0,0 +1,101 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatsEndpoint_jsonAPI(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	req.Header.Set("Accept", "application/vnd.api+json")
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := "application/vnd.api+json", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+
+	data, ok := decodeBody(t, w)["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected data resource object, got %s", w.Body.String())
+	}
+	if want, have := "file", data["type"]; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+	if want, have := "/hello.txt", data["id"]; want != have {
+		t.Errorf("expected id %#v, got %#v", want, have)
+	}
+	attributes, ok := data["attributes"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected attributes object, got %#v", data["attributes"])
+	}
+	if want, have := float64(6), attributes["size"]; want != have {
+		t.Errorf("expected size %#v, got %#v", want, have)
+	}
+	if _, ok := attributes["type"]; ok {
+		t.Errorf("expected no type attribute, got %#v", attributes["type"])
+	}
+}
+
+func TestListEndpoint_jsonAPI(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/list/", nil)
+	req.Header.Set("Accept", "application/vnd.api+json")
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+
+	body := decodeBody(t, w)
+	data, ok := body["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected data resource object, got %s", w.Body.String())
+	}
+	if want, have := "directory", data["type"]; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+	relationships, _ := data["relationships"].(map[string]interface{})
+	entries, _ := relationships["entries"].(map[string]interface{})
+	related, _ := entries["data"].([]interface{})
+	included, _ := body["included"].([]interface{})
+	if want, have := 2, len(related); want != have {
+		t.Fatalf("expected %d related entries, got %d", want, have)
+	}
+	if want, have := len(related), len(included); want != have {
+		t.Errorf("expected %d included resources, got %d", want, have)
+	}
+	identifier, _ := related[0].(map[string]interface{})
+	resource, _ := included[0].(map[string]interface{})
+	if want, have := identifier["id"], resource["id"]; want != have {
+		t.Errorf("expected included id %#v, got %#v", want, have)
+	}
+}
+
+func TestStatsEndpoint_jsonAPIError(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/stats/missing.txt", nil)
+	req.Header.Set("Accept", "application/vnd.api+json")
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := "application/vnd.api+json", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+
+	errors, ok := decodeBody(t, w)["errors"].([]interface{})
+	if !ok || len(errors) != 1 {
+		t.Fatalf("expected one error object, got %s", w.Body.String())
+	}
+	apiErr, _ := errors[0].(map[string]interface{})
+	if want, have := "404", apiErr["status"]; want != have {
+		t.Errorf("expected status %#v, got %#v", want, have)
+	}
+	if have, _ := apiErr["title"].(string); have == "" {
+		t.Errorf("expected error title, got %#v", apiErr["title"])
+	}
+	meta, _ := apiErr["meta"].(map[string]interface{})
+	if want, have := "missing.txt", meta["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+)
+
+// jsonpCallbackPattern matches JavaScript identifiers, optionally
+// dotted (e.g. "app.handle"), so that callbacks cannot inject script
+var jsonpCallbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// maxJSONPCallback is the maximum length of a callback name
+const maxJSONPCallback = 128
+
+// jsonpCallback returns the callback of the request, or empty string
+// if JSONP is disabled or not requested. An invalid callback name is
+// a bad request.
+func (cfg *Config) jsonpCallback(r *http.Request) (callback string, err error) {
+	if !cfg.JSONP {
+		return
+	}
+	callback = r.URL.Query().Get("callback")
+	if callback == "" {
+		return
+	}
+	if len(callback) > maxJSONPCallback || !jsonpCallbackPattern.MatchString(callback) {
+		callback, err = "", NewStatError(http.StatusBadRequest, r.URL.Path)
+	}
+	return
+}
+
+// wrapJSONP wraps the JSON body in a call of the callback. The leading
+// empty comment guards against content sniffing of the body.
+func wrapJSONP(callback string, body []byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("/**/")
+	buf.WriteString(callback)
+	buf.WriteByte('(')
+	buf.Write(bytes.TrimRight(body, "\n"))
+	buf.WriteString(");\n")
+	return buf.Bytes()
+}
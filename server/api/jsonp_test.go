@@ -0,0 +1,67 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestWithJSONP(t *testing.T) {
+
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt?callback=app.handle_1", nil)
+	w := serveRequest(http.Dir(testRoot), req, api.WithJSONP(true))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := "application/javascript; charset=utf-8", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+
+	body := w.Body.String()
+	const prefix, suffix = "/**/app.handle_1(", ");\n"
+	if !strings.HasPrefix(body, prefix) || !strings.HasSuffix(body, suffix) {
+		t.Fatalf("expected callback wrapped body, got %#v", body)
+	}
+	var stat map[string]interface{}
+	if err := json.Unmarshal([]byte(body[len(prefix):len(body)-len(suffix)]), &stat); err != nil {
+		t.Fatalf("error decoding wrapped JSON: %s", err)
+	}
+	if want, have := "hello.txt", stat["name"]; want != have {
+		t.Errorf("expected name %#v, got %#v", want, have)
+	}
+}
+
+func TestWithJSONP_invalidCallback(t *testing.T) {
+	for _, callback := range []string{
+		"alert(1);fn",
+		"fn</script>",
+		"1fn",
+		"fn..call",
+		strings.Repeat("f", 200),
+	} {
+		query := url.Values{"callback": {callback}}
+		req := httptest.NewRequest("GET", "/api/stats/hello.txt?"+query.Encode(), nil)
+		w := serveRequest(http.Dir(testRoot), req, api.WithJSONP(true))
+		if want, have := http.StatusBadRequest, w.Code; want != have {
+			t.Errorf("%#v: expected status %d, got %d", callback, want, have)
+		}
+		if strings.Contains(w.Body.String(), callback) {
+			t.Errorf("%#v: expected callback not to be echoed, got %s", callback, w.Body.String())
+		}
+	}
+}
+
+func TestWithJSONP_disabled(t *testing.T) {
+	w := serveAPI(http.Dir(testRoot), "/api/stats/hello.txt?callback=fn")
+	if want, have := "application/json", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+	if strings.HasPrefix(w.Body.String(), "/**/fn(") {
+		t.Errorf("expected plain JSON, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,381 @@
+package api_test
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestListEndpoint_pagination(t *testing.T) {
+
+	root := t.TempDir()
+	for i := 0; i < 25; i++ {
+		name := filepath.Join(root, fmt.Sprintf("file%02d.txt", i))
+		if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		offset int
+		count  int
+		first  string
+		next   interface{}
+	}{
+		{0, 10, "file00.txt", float64(10)},
+		{10, 10, "file10.txt", float64(20)},
+		{20, 5, "file20.txt", nil},
+	}
+
+	for _, test := range tests {
+		target := fmt.Sprintf("/api/list/?sort=name&offset=%d&limit=10", test.offset)
+		w := serveAPI(http.Dir(root), target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("offset=%d: expected status %d, got %d", test.offset, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		entries := entriesOf(t, body)
+		if want, have := test.count, len(entries); want != have {
+			t.Errorf("offset=%d: expected %d entries, got %d", test.offset, want, have)
+			continue
+		}
+		if want, have := test.first, entries[0].(map[string]interface{})["name"]; want != have {
+			t.Errorf("offset=%d: expected first entry %#v, got %#v", test.offset, want, have)
+		}
+		if want, have := float64(25), body["total"]; want != have {
+			t.Errorf("offset=%d: expected total %#v, got %#v", test.offset, want, have)
+		}
+		if next, ok := body["next"]; !ok || next != test.next {
+			t.Errorf("offset=%d: expected next %#v, got %#v", test.offset, test.next, next)
+		}
+	}
+}
+
+func TestListEndpoint_invalidPagination(t *testing.T) {
+	for _, query := range []string{
+		"offset=-1",
+		"offset=foo",
+		"limit=-10",
+		"limit=0",
+		"limit=foo",
+	} {
+		w := serveAPI(http.Dir(testRoot), "/api/list/?"+query)
+		if want, have := http.StatusBadRequest, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", query, want, have)
+		}
+	}
+}
+
+func TestListEndpoint_sort(t *testing.T) {
+
+	root := t.TempDir()
+	files := []struct {
+		name  string
+		size  int
+		mtime time.Time
+	}{
+		{"b.txt", 3, time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"a.txt", 3, time.Date(2003, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"c.txt", 1, time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"d.txt", 5, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, file := range files {
+		name := filepath.Join(root, file.name)
+		if err := os.WriteFile(name, make([]byte, file.size), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.Chtimes(name, file.mtime, file.mtime); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	subdir := filepath.Join(root, "e")
+	if err := os.Mkdir(subdir, 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	mtime := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(subdir, mtime, mtime); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		query string
+		names string
+	}{
+		{"sort=name", "a.txt, b.txt, c.txt, d.txt, e"},
+		{"sort=name&order=desc", "e, d.txt, c.txt, b.txt, a.txt"},
+		{"sort=mtime", "e, d.txt, b.txt, c.txt, a.txt"},
+		{"sort=mtime&order=desc", "a.txt, c.txt, b.txt, d.txt, e"},
+		{"", "a.txt, c.txt, b.txt, d.txt, e"},
+		{"sort=name&dirsFirst=true", "e, a.txt, b.txt, c.txt, d.txt"},
+		{"sort=size&dirsFirst=true", "e, c.txt, a.txt, b.txt, d.txt"},
+		{"sort=size&order=desc&dirsFirst=true", "e, d.txt, a.txt, b.txt, c.txt"},
+	}
+
+	for _, test := range tests {
+		w := serveAPI(http.Dir(root), "/api/list/?"+test.query)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.query, want, have)
+			continue
+		}
+		names := make([]string, 0, len(files)+1)
+		for _, entry := range entriesOf(t, decodeBody(t, w)) {
+			names = append(names, entry.(map[string]interface{})["name"].(string))
+		}
+		if want, have := test.names, strings.Join(names, ", "); want != have {
+			t.Errorf("%s:\nexpected: %s\ngot:      %s", test.query, want, have)
+		}
+	}
+}
+
+func TestListEndpoint_invalidSort(t *testing.T) {
+	for _, query := range []string{"sort=foo", "order=sideways"} {
+		w := serveAPI(http.Dir(testRoot), "/api/list/?"+query)
+		if want, have := http.StatusBadRequest, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", query, want, have)
+		}
+	}
+}
+
+func TestListEndpoint_glob(t *testing.T) {
+
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "data1.csv", "data2.csv", "data10.csv", "notes.md"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir.txt"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		glob  string
+		names string
+	}{
+		{"*.txt", "a.txt, b.txt, dir.txt"},
+		{"data?.csv", "data1.csv, data2.csv"},
+	}
+	for _, test := range tests {
+		w := serveAPI(http.Dir(root), "/api/list/?sort=name&glob="+url.QueryEscape(test.glob))
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.glob, want, have)
+			continue
+		}
+		names := []string{}
+		for _, entry := range entriesOf(t, decodeBody(t, w)) {
+			names = append(names, entry.(map[string]interface{})["name"].(string))
+		}
+		if want, have := test.names, strings.Join(names, ", "); want != have {
+			t.Errorf("%s:\nexpected: %s\ngot:      %s", test.glob, want, have)
+		}
+	}
+
+	// invalid pattern
+	w := serveAPI(http.Dir(root), "/api/list/?glob="+url.QueryEscape("["))
+	if want, have := http.StatusBadRequest, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestWithMaxEntries(t *testing.T) {
+
+	root := t.TempDir()
+	for i := 0; i < 15; i++ {
+		name := filepath.Join(root, fmt.Sprintf("file%02d.txt", i))
+		if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		target    string
+		count     int
+		truncated interface{}
+		next      interface{}
+	}{
+		{"/api/list/?sort=name", 10, true, float64(10)},
+		{"/api/list/?sort=name&limit=20", 10, true, float64(10)},
+		{"/api/list/?sort=name&offset=10", 5, nil, nil},
+		{"/api/list/?sort=name&limit=5", 5, nil, float64(5)},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		w := serveRequest(http.Dir(root), req, api.WithMaxEntries(10))
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := test.count, len(entriesOf(t, body)); want != have {
+			t.Errorf("%s: expected %d entries, got %d", test.target, want, have)
+		}
+		if want, have := test.truncated, body["truncated"]; want != have {
+			t.Errorf("%s: expected truncated %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.next, body["next"]; want != have {
+			t.Errorf("%s: expected next %#v, got %#v", test.target, want, have)
+		}
+		if want, have := float64(15), body["total"]; want != have {
+			t.Errorf("%s: expected total %#v, got %#v", test.target, want, have)
+		}
+	}
+}
+
+func TestListEndpoint_since(t *testing.T) {
+
+	root := t.TempDir()
+	cutoff := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	files := []struct {
+		name    string
+		modTime time.Time
+	}{
+		{"old.txt", cutoff.Add(-24 * time.Hour)},
+		{"new.txt", cutoff.Add(24 * time.Hour)},
+		{"dir/old.txt", cutoff.Add(-time.Hour)},
+		{"dir/new.txt", cutoff.Add(time.Hour)},
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, file := range files {
+		name := filepath.Join(root, filepath.FromSlash(file.name))
+		if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.Chtimes(name, file.modTime, file.modTime); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	// the directory itself is older, but still listed
+	if err := os.Chtimes(filepath.Join(root, "dir"), cutoff.Add(-48*time.Hour), cutoff.Add(-48*time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	since := url.QueryEscape(cutoff.Format(time.RFC3339))
+	body := decodeBody(t, serveAPI(http.Dir(root), "/api/list/?sort=name&since="+since))
+	if want, have := "dir,new.txt", strings.Join(entryNames(t, body), ","); want != have {
+		t.Errorf("expected entries %#v, got %#v", want, have)
+	}
+
+	body = decodeBody(t, serveAPI(http.Dir(root), "/api/tree/?sort=name&since="+since))
+	dir := entryNamed(t, entriesOf(t, body), "dir")
+	if want, have := "new.txt", strings.Join(entryNames(t, dir), ","); want != have {
+		t.Errorf("expected nested entries %#v, got %#v", want, have)
+	}
+
+	for _, target := range []string{"/api/list/?since=yesterday", "/api/tree/?since=2020-06-01"} {
+		w := serveAPI(http.Dir(root), target)
+		if want, have := http.StatusBadRequest, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+	}
+}
+
+// entryNames returns the names of the entries of a decoded directory
+func entryNames(t *testing.T, dir map[string]interface{}) (names []string) {
+	for _, entry := range entriesOf(t, dir) {
+		names = append(names, entry.(map[string]interface{})["name"].(string))
+	}
+	return
+}
+
+func TestListEndpoint_size(t *testing.T) {
+
+	root := t.TempDir()
+	for name, size := range map[string]int{"empty.txt": 0, "small.txt": 10, "medium.txt": 100, "large.txt": 1000} {
+		if err := os.WriteFile(filepath.Join(root, name), make([]byte, size), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		query string
+		names string
+	}{
+		{"", "dir,empty.txt,large.txt,medium.txt,small.txt"},
+		{"minSize=10", "dir,large.txt,medium.txt,small.txt"},
+		{"maxSize=100", "dir,empty.txt,medium.txt,small.txt"},
+		{"minSize=11&maxSize=100", "dir,medium.txt"},
+		{"minSize=2000", "dir"},
+	}
+	for _, test := range tests {
+		body := decodeBody(t, serveAPI(http.Dir(root), "/api/list/?sort=name&dirsFirst=true&"+test.query))
+		if want, have := test.names, strings.Join(entryNames(t, body), ","); want != have {
+			t.Errorf("%#v: expected entries %#v, got %#v", test.query, want, have)
+		}
+	}
+
+	for _, query := range []string{"minSize=big", "maxSize=-1", "minSize=1.5"} {
+		w := serveAPI(http.Dir(root), "/api/list/?"+query)
+		if want, have := http.StatusBadRequest, w.Code; want != have {
+			t.Errorf("%#v: expected status %d, got %d", query, want, have)
+		}
+	}
+}
+
+// vanishingFileSystem lists the entry of the given name, then
+// reports it removed before it could be stat, as a race with
+// its removal would
+type vanishingFileSystem struct {
+	http.FileSystem
+	name string
+}
+
+func (vfs vanishingFileSystem) Open(name string) (http.File, error) {
+	file, err := vfs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return vanishingDir{file, vfs.name}, nil
+}
+
+type vanishingDir struct {
+	http.File
+	name string
+}
+
+func (d vanishingDir) Readdir(count int) (files []os.FileInfo, err error) {
+	all, err := d.File.Readdir(count)
+	for _, file := range all {
+		if file.Name() == d.name {
+			err = &fs.PathError{Op: "lstat", Path: file.Name(), Err: fs.ErrNotExist}
+			continue
+		}
+		files = append(files, file)
+	}
+	return
+}
+
+func TestListEndpoint_vanished(t *testing.T) {
+	root := vanishingFileSystem{http.Dir(testRoot), "hello.txt"}
+	for _, target := range []string{"/api/list/", "/api/tree/"} {
+		w := serveAPI(root, target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+			continue
+		}
+		if want, have := "folder", strings.Join(entryNames(t, decodeBody(t, w)), ","); want != have {
+			t.Errorf("%s: expected entries %#v, got %#v", target, want, have)
+		}
+	}
+
+	// usage of the remaining files
+	body := decodeBody(t, serveAPI(root, "/api/stats/?recursive=true"))
+	if want, have := float64(1), body["fileCount"]; want != have {
+		t.Errorf("expected fileCount %#v, got %#v", want, have)
+	}
+}
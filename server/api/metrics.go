@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request
+// latency histogram
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// endpointMetrics are the metrics recorded for an endpoint
+type endpointMetrics struct {
+	requests uint64
+	errors   map[int]uint64
+	buckets  []uint64
+	sum      float64
+}
+
+// Metrics collects request counts, error counts by status and latency
+// histograms of the API endpoints. It serves them in the Prometheus
+// text exposition format as http.Handler.
+type Metrics struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointMetrics
+}
+
+// NewMetrics returns an empty Metrics collector
+func NewMetrics() *Metrics {
+	return &Metrics{
+		endpoints: make(map[string]*endpointMetrics),
+	}
+}
+
+// observe records a request to the endpoint
+func (m *Metrics) observe(endpoint string, code int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	em, ok := m.endpoints[endpoint]
+	if !ok {
+		em = &endpointMetrics{
+			errors:  make(map[int]uint64),
+			buckets: make([]uint64, len(latencyBuckets)),
+		}
+		m.endpoints[endpoint] = em
+	}
+	em.requests++
+	if code >= 400 {
+		em.errors[code]++
+	}
+	seconds := latency.Seconds()
+	em.sum += seconds
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			em.buckets[i]++
+		}
+	}
+}
+
+// instrument wraps the handler of the endpoint to record its metrics.
+// Returns the handler as is if m is nil.
+func (m *Metrics) instrument(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	if m == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		h(rec, r)
+		m.observe(endpoint, rec.code, time.Since(start))
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.endpoints))
+	for name := range m.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP goserve_api_requests_total Total requests by endpoint.")
+	fmt.Fprintln(w, "# TYPE goserve_api_requests_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "goserve_api_requests_total{endpoint=%q} %d\n", name, m.endpoints[name].requests)
+	}
+
+	fmt.Fprintln(w, "# HELP goserve_api_errors_total Total error responses by endpoint and status code.")
+	fmt.Fprintln(w, "# TYPE goserve_api_errors_total counter")
+	for _, name := range names {
+		em := m.endpoints[name]
+		codes := make([]int, 0, len(em.errors))
+		for code := range em.errors {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "goserve_api_errors_total{endpoint=%q,code=\"%d\"} %d\n", name, code, em.errors[code])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP goserve_api_request_duration_seconds Latency of requests by endpoint.")
+	fmt.Fprintln(w, "# TYPE goserve_api_request_duration_seconds histogram")
+	for _, name := range names {
+		em := m.endpoints[name]
+		for i, bound := range latencyBuckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			fmt.Fprintf(w, "goserve_api_request_duration_seconds_bucket{endpoint=%q,le=%q} %d\n", name, le, em.buckets[i])
+		}
+		fmt.Fprintf(w, "goserve_api_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", name, em.requests)
+		fmt.Fprintf(w, "goserve_api_request_duration_seconds_sum{endpoint=%q} %g\n", name, em.sum)
+		fmt.Fprintf(w, "goserve_api_request_duration_seconds_count{endpoint=%q} %d\n", name, em.requests)
+	}
+}
+
+// statusRecorder records the status code and number of bytes
+// written to the response
+type statusRecorder struct {
+	http.ResponseWriter
+	code        int
+	bytes       int64
+	wroteHeader bool
+}
+
+// Write implements http.ResponseWriter
+func (rec *statusRecorder) Write(b []byte) (n int, err error) {
+	rec.wroteHeader = true
+	n, err = rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return
+}
+
+// WriteHeader implements http.ResponseWriter
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.code = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap returns the underlying http.ResponseWriter
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
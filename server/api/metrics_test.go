@@ -0,0 +1,41 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestMetrics(t *testing.T) {
+
+	metrics := api.NewMetrics()
+	for _, target := range []string{
+		"/api/stats/hello.txt",
+		"/api/stats/folder",
+		"/api/stats/not-found",
+		"/api/list/",
+	} {
+		serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", target, nil), api.WithMetrics(metrics))
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, line := range []string{
+		`goserve_api_requests_total{endpoint="stats"} 3`,
+		`goserve_api_requests_total{endpoint="list"} 1`,
+		`goserve_api_errors_total{endpoint="stats",code="404"} 1`,
+		`goserve_api_request_duration_seconds_bucket{endpoint="stats",le="+Inf"} 3`,
+		`goserve_api_request_duration_seconds_count{endpoint="list"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected metrics to contain %#v, got:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `goserve_api_errors_total{endpoint="list"`) {
+		t.Errorf("expected no errors of list endpoint, got:\n%s", body)
+	}
+}
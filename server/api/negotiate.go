@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// jsonMediaType is the default media type of responses
+const jsonMediaType = "application/json"
+
+// mediaQuality returns the quality of the media type by the most
+// specific of the accepted media ranges: the type itself, its type
+// wildcard (e.g. "text/*") or "*/*". ok is false if none matches.
+func mediaQuality(accepted map[string]float64, mediaType string) (q float64, ok bool) {
+	if q, ok = accepted[mediaType]; ok {
+		return
+	}
+	if typ, _, found := strings.Cut(mediaType, "/"); found {
+		if q, ok = accepted[typ+"/*"]; ok {
+			return
+		}
+	}
+	q, ok = accepted["*/*"]
+	return
+}
+
+// negotiateMedia returns the offered media type of the highest quality
+// by the Accept header of the request. Ties are broken by the order of
+// offers. The first offer is returned if the request has no Accept
+// header, or accepts none of the offers.
+func negotiateMedia(r *http.Request, offers ...string) string {
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return offers[0]
+	}
+	accepted := parseQuality(header)
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		if q, ok := mediaQuality(accepted, offer); ok && q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
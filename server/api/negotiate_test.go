@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestHandleEndpoint_negotiate(t *testing.T) {
+
+	tests := []struct {
+		target      string
+		accept      string
+		contentType string
+	}{
+		{"/api/list/", "", "application/json"},
+		{"/api/list/", "*/*", "application/json"},
+		{"/api/list/", "application/json;q=0.9, text/html;q=1.0", "text/html; charset=utf-8"},
+		{"/api/list/", "text/html;q=0.5, application/json", "application/json"},
+		{"/api/list/", "application/*;q=0.5, text/html;q=0.4", "application/json"},
+		{"/api/list/", "application/x-ndjson;q=0.9, application/json;q=0.8", "application/x-ndjson"},
+		{"/api/list/", "application/json;q=0.5, application/x-ndjson;q=0.4", "application/json"},
+		{"/api/list/", "application/vnd.api+json, application/json;q=0.1", "application/vnd.api+json"},
+		{"/api/list/", "image/png", "application/json"},
+		{"/api/stats/hello.txt", "text/html;q=1.0, application/json;q=0.1", "application/json"},
+		{"/api/stats/hello.txt", "application/json;q=0.5, application/vnd.api+json;q=0.6", "application/vnd.api+json"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		w := serveRequest(http.Dir(testRoot), req)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s %#v: expected status %d, got %d", test.target, test.accept, want, have)
+			continue
+		}
+		if want, have := test.contentType, w.Header().Get("Content-Type"); want != have {
+			t.Errorf("%s %#v: expected Content-Type %#v, got %#v", test.target, test.accept, want, have)
+		}
+	}
+}
+
+func TestServeAPI_negotiateStatsQuality(t *testing.T) {
+
+	handler := api.ServeAPI("/api", http.Dir(testRoot))(http.FileServer(http.Dir(testRoot)))
+	tests := []struct {
+		header string
+		value  string
+		stats  bool
+	}{
+		{"Accept", "application/goserve+json, */*;q=0.8", true},
+		{"Accept", "application/goserve+json;q=0.5, text/html", false},
+		{"Accept", "application/goserve+json;q=0", false},
+		{"Content-Type", "application/goserve+json; charset=utf-8", true},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/hello.txt", nil)
+		req.Header.Set(test.header, test.value)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if want, have := test.stats, strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"); want != have {
+			t.Errorf("%s %#v: expected stats %t, got Content-Type %#v", test.header, test.value, want, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestServeAPI_negotiateErrors(t *testing.T) {
+
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/api/archive/?format=rar", http.StatusBadRequest},
+		{"/api/archive/hello.txt", http.StatusBadRequest},
+		{"/api/read/folder", http.StatusBadRequest},
+		{"/api/nosuchendpoint", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		req.Header.Set("Accept", "text/plain")
+		w := serveRequest(http.Dir(testRoot), req)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+		}
+		if want, have := "text/plain; charset=utf-8", w.Header().Get("Content-Type"); want != have {
+			t.Errorf("%s: expected Content-Type %#v, got %#v", test.target, want, have)
+		}
+	}
+}
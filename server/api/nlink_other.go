@@ -0,0 +1,10 @@
+//go:build !unix
+
+package api
+
+import "os"
+
+// fileNlink is not supported on this platform
+func fileNlink(stat os.FileInfo) uint64 {
+	return 0
+}
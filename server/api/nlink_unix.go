@@ -0,0 +1,18 @@
+//go:build unix
+
+package api
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileNlink returns the number of hard links of the file from its
+// syscall.Stat_t, or 0 if the file info does not provide one
+func fileNlink(stat os.FileInfo) uint64 {
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(sys.Nlink)
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatsEndpoint_nlink(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	body := decodeBody(t, serveAPI(http.Dir(root), "/api/stats/file.txt"))
+	if want, have := float64(1), body["nlink"]; want != have {
+		t.Errorf("expected nlink %#v, got %#v", want, have)
+	}
+
+	if err := os.Link(filepath.Join(root, "file.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skipf("hard link not supported: %s", err)
+	}
+	body = decodeBody(t, serveAPI(http.Dir(root), "/api/stats/file.txt"))
+	if want, have := float64(2), body["nlink"]; want != have {
+		t.Errorf("expected nlink %#v, got %#v", want, have)
+	}
+}
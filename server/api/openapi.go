@@ -0,0 +1,188 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// openAPISchemas are the schemas of the API responses
+var openAPISchemas = map[string]interface{}{
+	"FileStat": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"type", "name", "path", "parent", "size", "mtime", "mode", "perm"},
+		"properties": map[string]interface{}{
+			"type":        map[string]interface{}{"type": "string", "enum": []string{"file"}},
+			"name":        map[string]interface{}{"type": "string"},
+			"path":        map[string]interface{}{"type": "string"},
+			"parent":      map[string]interface{}{"type": "string"},
+			"size":        map[string]interface{}{"type": "integer"},
+			"mtime":       map[string]interface{}{},
+			"mode":        map[string]interface{}{"type": "string"},
+			"perm":        map[string]interface{}{"type": "string"},
+			"isSymlink":   map[string]interface{}{"type": "boolean"},
+			"contentType": map[string]interface{}{"type": "string"},
+			"target":      map[string]interface{}{"type": "string"},
+			"realPath":    map[string]interface{}{"type": "string"},
+			"checksum": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"algorithm": map[string]interface{}{"type": "string"},
+					"digest":    map[string]interface{}{"type": "string"},
+				},
+			},
+			"nlink": map[string]interface{}{"type": "integer"},
+			"dev":   map[string]interface{}{"type": "integer"},
+			"ino":   map[string]interface{}{"type": "integer"},
+		},
+	},
+	"DirStat": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"type", "name", "path", "parent", "mtime", "mode", "perm"},
+		"properties": map[string]interface{}{
+			"type":      map[string]interface{}{"type": "string", "enum": []string{"directory"}},
+			"name":      map[string]interface{}{"type": "string"},
+			"path":      map[string]interface{}{"type": "string"},
+			"parent":    map[string]interface{}{"type": "string"},
+			"mtime":     map[string]interface{}{},
+			"mode":      map[string]interface{}{"type": "string"},
+			"perm":      map[string]interface{}{"type": "string"},
+			"isSymlink": map[string]interface{}{"type": "boolean"},
+			"realPath":  map[string]interface{}{"type": "string"},
+			"entries": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"$ref": "#/components/schemas/Stat"},
+			},
+			"total":               map[string]interface{}{"type": "integer"},
+			"next":                map[string]interface{}{"type": "integer", "nullable": true},
+			"truncated":           map[string]interface{}{"type": "boolean"},
+			"totalSize":           map[string]interface{}{"type": "integer"},
+			"fileCount":           map[string]interface{}{"type": "integer"},
+			"entryCount":          map[string]interface{}{"type": "integer"},
+			"entryCountTruncated": map[string]interface{}{"type": "boolean"},
+		},
+	},
+	"Summary": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"path", "fileCount", "dirCount", "totalSize"},
+		"properties": map[string]interface{}{
+			"path":      map[string]interface{}{"type": "string"},
+			"fileCount": map[string]interface{}{"type": "integer"},
+			"dirCount":  map[string]interface{}{"type": "integer"},
+			"totalSize": map[string]interface{}{"type": "integer"},
+			"largest": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{"type": "string"},
+					"size": map[string]interface{}{"type": "integer"},
+				},
+			},
+			"newest": map[string]interface{}{"type": "string", "format": "date-time"},
+		},
+	},
+	"Stat": map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"$ref": "#/components/schemas/FileStat"},
+			map[string]interface{}{"$ref": "#/components/schemas/DirStat"},
+		},
+	},
+	"StatError": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"status", "code", "message"},
+		"properties": map[string]interface{}{
+			"status":  map[string]interface{}{"type": "string", "enum": []string{"error"}},
+			"code":    map[string]interface{}{"type": "integer"},
+			"path":    map[string]interface{}{"type": "string"},
+			"message": map[string]interface{}{"type": "string"},
+		},
+	},
+}
+
+// openAPIDocument generates the OpenAPI 3 document of the endpoints
+// of the API mounted at base
+func openAPIDocument(base string, infos []endpointInfo) map[string]interface{} {
+
+	errorResponse := map[string]interface{}{
+		"description": "Error",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/StatError"},
+			},
+		},
+	}
+
+	paths := make(map[string]interface{})
+	for _, info := range infos {
+		route := "/" + info.Name
+		var params []interface{}
+		if info.path {
+			route += "/{path}"
+			params = append(params, map[string]interface{}{
+				"name":     "path",
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, param := range info.params {
+			params = append(params, map[string]interface{}{
+				"name":   param,
+				"in":     "query",
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		success := map[string]interface{}{"description": "Success"}
+		if info.schema != "" {
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"$ref": "#/components/schemas/" + info.schema},
+				},
+			}
+		}
+
+		operations := make(map[string]interface{})
+		for _, method := range info.Methods {
+			operation := map[string]interface{}{
+				"summary": info.Description,
+				"responses": map[string]interface{}{
+					"200":     success,
+					"default": errorResponse,
+				},
+			}
+			if params != nil {
+				operation["parameters"] = params
+			}
+			operations[strings.ToLower(method)] = operation
+		}
+		paths[route] = operations
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "goserve API",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": base},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": openAPISchemas,
+		},
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document of the endpoints of the
+// API mounted at base
+func handleOpenAPI(base string, infos []endpointInfo) http.HandlerFunc {
+	doc := openAPIDocument(base+"/", infos)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, r, http.StatusOK, doc)
+	}
+}
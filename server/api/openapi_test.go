@@ -0,0 +1,43 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestOpenAPIEndpoint(t *testing.T) {
+
+	w := serveAPI(http.Dir(testRoot), "/api/openapi.json")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+
+	var doc struct {
+		OpenAPI    string                            `json:"openapi"`
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("error decoding document: %s", err)
+	}
+	if doc.OpenAPI == "" {
+		t.Errorf("expected openapi version")
+	}
+	stats, ok := doc.Paths["/stats/{path}"]
+	if !ok {
+		t.Fatalf("expected stats path in %#v", doc.Paths)
+	}
+	for _, method := range []string{"get", "head", "post"} {
+		if _, ok := stats[method]; !ok {
+			t.Errorf("expected %s operation of stats", method)
+		}
+	}
+	for _, schema := range []string{"FileStat", "DirStat", "StatError"} {
+		if _, ok := doc.Components.Schemas[schema]; !ok {
+			t.Errorf("expected schema %s", schema)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package api
+
+// Owner is the owner user and group of a file or directory
+type Owner struct {
+	UID   uint32 `json:"uid"`
+	GID   uint32 `json:"gid"`
+	User  string `json:"owner,omitempty"`
+	Group string `json:"group,omitempty"`
+}
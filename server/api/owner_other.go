@@ -0,0 +1,10 @@
+//go:build !unix
+
+package api
+
+import "os"
+
+// fileOwner is not supported on this platform
+func fileOwner(stat os.FileInfo) *Owner {
+	return nil
+}
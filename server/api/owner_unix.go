@@ -0,0 +1,56 @@
+//go:build unix
+
+package api
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// ownerNames caches user and group names resolved by id
+var ownerNames sync.Map
+
+// lookupName resolves the name of a user or group id with lookup,
+// or returns empty string if it cannot be resolved
+func lookupName(kind string, id uint32, lookup func(id string) (string, error)) string {
+	key := kind + ":" + strconv.FormatUint(uint64(id), 10)
+	if name, ok := ownerNames.Load(key); ok {
+		return name.(string)
+	}
+	name, err := lookup(strconv.FormatUint(uint64(id), 10))
+	if err != nil {
+		name = ""
+	}
+	ownerNames.Store(key, name)
+	return name
+}
+
+// fileOwner returns the owner of the file from its syscall.Stat_t,
+// or nil if the file info does not provide one
+func fileOwner(stat os.FileInfo) *Owner {
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return &Owner{
+		UID: sys.Uid,
+		GID: sys.Gid,
+		User: lookupName("user", sys.Uid, func(id string) (string, error) {
+			u, err := user.LookupId(id)
+			if err != nil {
+				return "", err
+			}
+			return u.Username, nil
+		}),
+		Group: lookupName("group", sys.Gid, func(id string) (string, error) {
+			g, err := user.LookupGroupId(id)
+			if err != nil {
+				return "", err
+			}
+			return g.Name, nil
+		}),
+	}
+}
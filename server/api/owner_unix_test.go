@@ -0,0 +1,33 @@
+//go:build unix
+
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatsEndpoint_owner(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, target := range []string{"/api/stats/file.txt", "/api/stats/"} {
+		w := serveAPI(http.Dir(root), target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := float64(os.Getuid()), body["uid"]; want != have {
+			t.Errorf("%s: expected uid %#v, got %#v", target, want, have)
+		}
+		if want, have := float64(os.Getgid()), body["gid"]; want != have {
+			t.Errorf("%s: expected gid %#v, got %#v", target, want, have)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRateBuckets bounds the clients tracked before the buckets refilled
+// in full, the same as untracked, are swept
+const maxRateBuckets = 10000
+
+// RateLimitConfig is the rate limit of the requests of the API by
+// client IP, as token buckets
+type RateLimitConfig struct {
+
+	// Rate of requests per second refilled to each client. Requests
+	// are not limited if not positive.
+	Rate float64
+
+	// Burst of requests of a client above the rate. Defaults to 1.
+	Burst int
+
+	// TrustForwardedFor takes the client IP from the X-Forwarded-For
+	// header, as appended by the proxy, over the remote address. Only
+	// enable behind a proxy that sets the header.
+	TrustForwardedFor bool
+}
+
+// rateBucket is the token bucket of a client
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter tracks the token buckets of the clients
+type rateLimiter struct {
+	RateLimitConfig
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+// newRateLimiter returns the limiter of the config, or nil if the
+// requests are not limited
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	if config.Rate <= 0 {
+		return nil
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	return &rateLimiter{
+		RateLimitConfig: config,
+		buckets:         make(map[string]*rateBucket),
+	}
+}
+
+// clientIP returns the IP of the client of the request
+func (l *rateLimiter) clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); l.TrustForwardedFor && forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+			return ip
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// take takes a token of the bucket of the client. If none is left,
+// returns false and the delay until the next token.
+func (l *rateLimiter) take(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= maxRateBuckets {
+			l.sweep(now)
+		}
+		bucket = &rateBucket{tokens: float64(l.Burst), last: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(float64(l.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*l.Rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.Rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep forgets the buckets refilled in full by now
+func (l *rateLimiter) sweep(now time.Time) {
+	for client, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.Rate >= float64(l.Burst) {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// limit writes too many requests if the client of the request is over
+// the rate limit, and reports if it did, the request then not to be
+// served
+func (l *rateLimiter) limit(w http.ResponseWriter, r *http.Request) bool {
+	if l == nil {
+		return false
+	}
+	ok, delay := l.take(l.clientIP(r))
+	if ok {
+		return false
+	}
+	seconds := int64((delay + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	writeStatError(w, r, NewStatError(http.StatusTooManyRequests, r.URL.Path))
+	return true
+}
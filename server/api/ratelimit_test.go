@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestWithRateLimit(t *testing.T) {
+
+	handler := api.ServeAPI("/api", http.Dir(testRoot), api.WithRateLimit(api.RateLimitConfig{
+		Rate:  0.01,
+		Burst: 2,
+	}))(http.NotFoundHandler())
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if want, have := http.StatusOK, serve("192.0.2.1:1234").Code; want != have {
+			t.Fatalf("request %d: expected status %d, got %d", i, want, have)
+		}
+	}
+	w := serve("192.0.2.1:5678")
+	if want, have := http.StatusTooManyRequests, w.Code; want != have {
+		t.Errorf("expected status %d over the limit, got %d", want, have)
+	}
+	if want, have := "100", w.Header().Get("Retry-After"); want != have {
+		t.Errorf("expected Retry-After %#v, got %#v", want, have)
+	}
+
+	// other clients have their own bucket
+	if want, have := http.StatusOK, serve("192.0.2.2:1234").Code; want != have {
+		t.Errorf("expected status %d for another client, got %d", want, have)
+	}
+}
+
+func TestWithRateLimit_forwardedFor(t *testing.T) {
+
+	tests := []struct {
+		trust bool
+		code  int
+	}{
+		{false, http.StatusTooManyRequests},
+		{true, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		handler := api.ServeAPI("/api", http.Dir(testRoot), api.WithRateLimit(api.RateLimitConfig{
+			Rate:              0.01,
+			TrustForwardedFor: test.trust,
+		}))(http.NotFoundHandler())
+		var w *httptest.ResponseRecorder
+		for _, client := range []string{"198.51.100.1", "203.0.113.7, 198.51.100.2"} {
+			req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", client)
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+		}
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("trust %t: expected status %d, got %d", test.trust, want, have)
+		}
+	}
+}
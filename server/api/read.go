@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+)
+
+// handleRead serves the content of the file at the request path,
+// honoring Range and conditional request headers
+func handleRead(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// only read methods are allowed
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		ctx := withFilesystem(withEndpointContext(r.Context(), r), cfg.Root)
+		ctx = withConfig(ctx, cfg)
+
+		path, err := scopePath(r.URL.Path)
+		if err != nil {
+			writeStatError(w, r, err)
+			return
+		}
+		file, stat, err := openFile(ctx, path)
+		if err != nil {
+			writeStatError(w, r, err)
+			return
+		}
+		defer file.Close()
+
+		// only regular files have content to read
+		if !stat.Mode().IsRegular() {
+			writeStatError(w, r, NewStatError(http.StatusBadRequest, path))
+			return
+		}
+
+		// strong entity tag of content, if configured, for
+		// conditional and If-Range requests
+		if cfg.StrongETag {
+			etag, err := contentETag(ctx, path, stat, file)
+			if err != nil {
+				writeStatError(w, r, err)
+				return
+			}
+			w.Header().Set("ETag", etag)
+		}
+
+		http.ServeContent(w, r, stat.Name(), stat.ModTime(), file)
+	}
+}
@@ -0,0 +1,69 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadEndpoint(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		rangeHeader  string
+		code         int
+		body         string
+		contentRange string
+	}{
+		{"full", "", http.StatusOK, "hello\n", ""},
+		{"range", "bytes=1-3", http.StatusPartialContent, "ell", "bytes 1-3/6"},
+		{"suffix range", "bytes=-2", http.StatusPartialContent, "o\n", "bytes 4-5/6"},
+		{"out of bounds", "bytes=10-20", http.StatusRequestedRangeNotSatisfiable, "", "bytes */6"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/api/read/hello.txt", nil)
+		if test.rangeHeader != "" {
+			req.Header.Set("Range", test.rangeHeader)
+		}
+		w := serveRequest(http.Dir(testRoot), req)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+			continue
+		}
+		if want, have := test.contentRange, w.Header().Get("Content-Range"); want != have {
+			t.Errorf("%s: expected Content-Range %#v, got %#v", test.name, want, have)
+		}
+		if test.code != http.StatusRequestedRangeNotSatisfiable {
+			if want, have := test.body, w.Body.String(); want != have {
+				t.Errorf("%s: expected body %#v, got %#v", test.name, want, have)
+			}
+		}
+	}
+}
+
+func TestReadEndpoint_multiRange(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/read/hello.txt", nil)
+	req.Header.Set("Range", "bytes=0-0,4-5")
+	w := serveRequest(http.Dir(testRoot), req)
+	if want, have := http.StatusPartialContent, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if have := w.Header().Get("Content-Type"); !strings.HasPrefix(have, "multipart/byteranges") {
+		t.Errorf("expected multipart/byteranges content type, got %#v", have)
+	}
+}
+
+func TestReadEndpoint_errors(t *testing.T) {
+	for target, code := range map[string]int{
+		"/api/read/not-found": http.StatusNotFound,
+		"/api/read/folder":    http.StatusBadRequest,
+		"/api/read/../secret": http.StatusForbidden,
+	} {
+		w := serveAPI(http.Dir(testRoot), target)
+		if want, have := code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+	}
+}
@@ -1,13 +1,23 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-midway/midway"
@@ -32,52 +42,286 @@ type FileInfo struct {
 }
 
 // FileStat stores and display a file's information as JSON
+//
+// Path and Parent are scoped paths, relative to the root without
+// leading slash. In JSON, they are root relative paths with a single
+// leading slash, such as "/folder/nested.txt" and "/folder", or host
+// absolute paths if configured with AbsolutePaths.
 type FileStat struct {
-	Name  string
-	Path  string
-	Size  int64
-	MTime time.Time
+	Name   string
+	Path   string
+	Parent string
+	Size   int64
+	MTime  time.Time
+	Mode   os.FileMode
+
+	// CTime is the status change time, and BirthTime the creation
+	// time, where available on the platform
+	CTime     time.Time
+	BirthTime time.Time
+
+	// ContentType of the file, by extension or content sniffing
+	ContentType string
+
+	// Target is the root relative destination of a symbolic link,
+	// if the link is not followed
+	Target string
+
+	// RealPath is the root relative path of the file a symbolic link
+	// resolves to, with a leading slash, if within the root
+	RealPath string
+
+	// TimeFormat of MTime in JSON
+	TimeFormat TimeFormat
+
+	// Checksum of the file content, if requested
+	Checksum *Checksum
+
+	// Owner of the file, if known on the platform
+	Owner *Owner
+
+	// Nlink is the number of hard links of the file, if known on
+	// the platform
+	Nlink uint64
+
+	// Dev and Ino are the device and inode numbers of the file, if
+	// known on the platform, identical for the hard links of a file
+	Dev uint64
+	Ino uint64
+
+	// Access of the server process to the file, if known
+	Access *Access
+
+	// contentTag is the strong entity tag of the content, if computed
+	contentTag string
+
+	// absRoot is the host absolute path of the root, if paths
+	// are displayed absolute
+	absRoot string
 }
 
 // MarshalJSON implements encoding/json.Marshaler
 func (file FileStat) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Type  string    `json:"type"`
-		Name  string    `json:"name"`
-		Path  string    `json:"path"`
-		Size  int64     `json:"size"`
-		MTime time.Time `json:"mtime"`
+		Type        string      `json:"type"`
+		Name        string      `json:"name"`
+		Path        string      `json:"path"`
+		Parent      string      `json:"parent"`
+		Size        int64       `json:"size"`
+		MTime       interface{} `json:"mtime"`
+		CTime       interface{} `json:"ctime,omitempty"`
+		BirthTime   interface{} `json:"birthtime,omitempty"`
+		Mode        string      `json:"mode"`
+		Perm        string      `json:"perm"`
+		IsSymlink   bool        `json:"isSymlink,omitempty"`
+		ContentType string      `json:"contentType,omitempty"`
+		Target      string      `json:"target,omitempty"`
+		RealPath    string      `json:"realPath,omitempty"`
+		Checksum    *Checksum   `json:"checksum,omitempty"`
+		Nlink       uint64      `json:"nlink,omitempty"`
+		Dev         uint64      `json:"dev,omitempty"`
+		Ino         uint64      `json:"ino,omitempty"`
+		*Owner
+		*Access
 	}{
-		Type:  "file",
-		Name:  file.Name,
-		Path:  file.Path,
-		Size:  file.Size,
-		MTime: file.MTime,
+		Type:        "file",
+		Name:        file.Name,
+		Path:        displayPath(file.absRoot, file.Path),
+		Parent:      displayPath(file.absRoot, file.Parent),
+		Size:        file.Size,
+		MTime:       file.TimeFormat.format(file.MTime),
+		CTime:       file.TimeFormat.formatOptional(file.CTime),
+		BirthTime:   file.TimeFormat.formatOptional(file.BirthTime),
+		Mode:        modeString(file.Mode),
+		Perm:        file.Mode.Perm().String(),
+		IsSymlink:   file.Mode&os.ModeSymlink != 0,
+		ContentType: file.ContentType,
+		Target:      file.Target,
+		RealPath:    displayRealPath(file.absRoot, file.RealPath),
+		Checksum:    file.Checksum,
+		Nlink:       file.Nlink,
+		Dev:         file.Dev,
+		Ino:         file.Ino,
+		Owner:       file.Owner,
+		Access:      file.Access,
 	})
 }
 
 // DirStat stores and display a directory's information as JSON
+//
+// Path and Parent are scoped paths, as those of FileStat. In JSON, the
+// root has the path "/" and no parent.
 type DirStat struct {
-	Name  string
-	Path  string
-	MTime time.Time
+	Name   string
+	Path   string
+	Parent string
+	MTime  time.Time
+	Mode   os.FileMode
+
+	// CTime is the status change time, and BirthTime the creation
+	// time, where available on the platform
+	CTime     time.Time
+	BirthTime time.Time
+
+	// TimeFormat of MTime in JSON
+	TimeFormat TimeFormat
+
+	// Entries of the directory, if listed. Each of them is
+	// either FileStat or DirStat. Omitted from JSON if nil.
+	Entries []interface{}
+
+	// Page of the entries listed, if any
+	Page *Page
+
+	// Usage of the directory tree, if aggregated
+	Usage *Usage
+
+	// EntryCount is the number of entries of the directory, if counted
+	// by its stats. EntryCountTruncated is true if the count stopped at
+	// the bound configured, the directory having more entries.
+	EntryCount          *int
+	EntryCountTruncated bool
+
+	// Truncated is true if the entries of the directory are omitted
+	// from a tree at the maximum depth
+	Truncated bool
+
+	// Owner of the directory, if known on the platform
+	Owner *Owner
+
+	// Access of the server process to the directory, if known
+	Access *Access
+
+	// RealPath is the root relative path of the directory a symbolic
+	// link resolves to, with a leading slash, if within the root
+	RealPath string
+
+	// absRoot is the host absolute path of the root, if paths
+	// are displayed absolute
+	absRoot string
+}
+
+// Page describes the page of directory entries listed
+type Page struct {
+	Offset int
+	Limit  int
+	Total  int
+
+	// Next is the offset of the next page, or nil if
+	// this is the last page
+	Next *int
+
+	// Truncated is true if the entries are capped by the
+	// maximum listing size. Next continues the listing.
+	Truncated bool
 }
 
 // MarshalJSON implements encoding/json.Marshaler
 func (file DirStat) MarshalJSON() ([]byte, error) {
+	var entries *[]interface{}
+	if file.Entries != nil {
+		entries = &file.Entries
+	}
+	var total *int
+	var next json.RawMessage
+	var truncated bool
+	var totalSize *int64
+	var fileCount *int
+	if file.Usage != nil {
+		totalSize = &file.Usage.TotalSize
+		fileCount = &file.Usage.FileCount
+	}
+	var parent string
+	if file.Path != "" {
+		parent = displayPath(file.absRoot, file.Parent)
+	}
+	if file.Page != nil {
+		total = &file.Page.Total
+		next, _ = json.Marshal(file.Page.Next)
+		truncated = file.Page.Truncated
+	}
+	truncated = truncated || file.Truncated
 	return json.Marshal(struct {
-		Type  string    `json:"type"`
-		Name  string    `json:"name"`
-		Path  string    `json:"path"`
-		MTime time.Time `json:"mtime"`
+		Type                string          `json:"type"`
+		Name                string          `json:"name"`
+		Path                string          `json:"path"`
+		Parent              string          `json:"parent"`
+		MTime               interface{}     `json:"mtime"`
+		CTime               interface{}     `json:"ctime,omitempty"`
+		BirthTime           interface{}     `json:"birthtime,omitempty"`
+		Mode                string          `json:"mode"`
+		Perm                string          `json:"perm"`
+		IsSymlink           bool            `json:"isSymlink,omitempty"`
+		RealPath            string          `json:"realPath,omitempty"`
+		Entries             *[]interface{}  `json:"entries,omitempty"`
+		Total               *int            `json:"total,omitempty"`
+		Next                json.RawMessage `json:"next,omitempty"`
+		Truncated           bool            `json:"truncated,omitempty"`
+		TotalSize           *int64          `json:"totalSize,omitempty"`
+		FileCount           *int            `json:"fileCount,omitempty"`
+		EntryCount          *int            `json:"entryCount,omitempty"`
+		EntryCountTruncated bool            `json:"entryCountTruncated,omitempty"`
+		*Owner
+		*Access
 	}{
-		Type:  "directory",
-		Name:  file.Name,
-		Path:  file.Path,
-		MTime: file.MTime,
+		Type:                "directory",
+		Name:                file.Name,
+		Path:                displayPath(file.absRoot, file.Path),
+		Parent:              parent,
+		MTime:               file.TimeFormat.format(file.MTime),
+		CTime:               file.TimeFormat.formatOptional(file.CTime),
+		BirthTime:           file.TimeFormat.formatOptional(file.BirthTime),
+		Mode:                modeString(file.Mode),
+		Perm:                file.Mode.Perm().String(),
+		IsSymlink:           file.Mode&os.ModeSymlink != 0,
+		RealPath:            displayRealPath(file.absRoot, file.RealPath),
+		Entries:             entries,
+		Total:               total,
+		Next:                next,
+		Truncated:           truncated,
+		TotalSize:           totalSize,
+		FileCount:           fileCount,
+		EntryCount:          file.EntryCount,
+		EntryCountTruncated: file.EntryCountTruncated,
+		Owner:               file.Owner,
+		Access:              file.Access,
 	})
 }
 
+// rootPath formats the scoped path as root relative path with a
+// single leading slash
+func rootPath(scoped string) string {
+	return "/" + strings.TrimLeft(scoped, "/")
+}
+
+// displayPath formats the scoped path for JSON: root relative, or
+// host absolute if the absolute root is given
+func displayPath(absRoot, scoped string) string {
+	if absRoot == "" {
+		return rootPath(scoped)
+	}
+	return filepath.Join(absRoot, filepath.FromSlash(scoped))
+}
+
+// displayRealPath formats the real path for JSON as displayPath,
+// or empty string if there is none
+func displayRealPath(absRoot, real string) string {
+	if real == "" {
+		return ""
+	}
+	return displayPath(absRoot, real)
+}
+
+// modeString formats the permission bits of mode as
+// an octal string (e.g. "0644")
+func modeString(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", mode.Perm())
+}
+
+// StatusClientClosedRequest is the nginx convention of status code
+// for requests cancelled by the client closing the connection
+const StatusClientClosedRequest = 499
+
 // StatError represents an error in JSON format
 type StatError struct {
 	Code int
@@ -86,6 +330,9 @@ type StatError struct {
 
 // Message return message for a given error
 func (err StatError) Message() string {
+	if err.Code == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
 	msg := http.StatusText(err.Code)
 	if msg == "" {
 		return "unknown error"
@@ -121,315 +368,1291 @@ func (err StatError) MarshalJSON() ([]byte, error) {
 	})
 }
 
-func statsEndpoint(ctx context.Context, req interface{}) (stats interface{}, err error) {
+// scopePath cleans the requested path and makes sure it
+// never escapes the root of the file system
+func scopePath(reqPath string) (scoped string, err error) {
+
+	// null bytes are never valid in a path
+	if strings.IndexByte(reqPath, 0) >= 0 {
+		err = NewStatError(http.StatusBadRequest, reqPath)
+		return
+	}
+
+	// path traversal attempt. repeated slashes are collapsed, so
+	// "//foo//bar" resolves the same as "/foo/bar" on all platforms
+	scoped = path.Clean(reqPath)
+	if scoped == ".." || strings.HasPrefix(scoped, "../") {
+		err = NewStatError(http.StatusForbidden, reqPath)
+		return
+	}
 
-	path := req.(string)
+	scoped = strings.TrimLeft(scoped, "/")
+	if scoped == "." {
+		scoped = ""
+	}
+	return
+}
 
-	// TODO: rewrite with FileSystem
-	stat, err := os.Stat(path)
+// openFile opens the scoped path within the root file system
+// and stats it. Errors are translated to StatError when possible.
+func openFile(ctx context.Context, path string) (file http.File, stat os.FileInfo, err error) {
 
-	// if file not found
-	if os.IsNotExist(err) {
+	// hidden files are treated as not found
+	if getConfig(ctx).HideDotfiles && isHidden(path) {
 		err = NewStatError(http.StatusNotFound, path)
 		return
 	}
 
-	// permission problem
-	if err != nil {
-		perr, _ := err.(*os.PathError)
-		if perr.Err.Error() == os.ErrPermission.Error() {
-			err = NewStatError(http.StatusForbidden, path)
+	file, err = getFilesystem(ctx).Open("/" + path)
+
+	// retry a case-insensitive match of the name, if configured
+	if errors.Is(err, fs.ErrNotExist) && getConfig(ctx).CaseInsensitive && path != "" {
+		var resolved string
+		if resolved, err = resolveCase(ctx, path); err != nil {
+			return
 		}
+		file, err = getFilesystem(ctx).Open("/" + resolved)
+	}
+
+	// file not found, permission problem or else
+	if err != nil {
+		err = fileError(err, path)
 		return
 	}
 
-	// for files
-	if stat.Mode().IsRegular() {
+	if stat, err = file.Stat(); err != nil {
+		file.Close()
+		file = nil
+		return
+	}
 
-		// test permission
-		var file *os.File
-		file, err = os.OpenFile(path, os.O_RDONLY, 0444)
-		if err != nil {
-			perr, _ := err.(*os.PathError)
-			if perr.Err.Error() == os.ErrPermission.Error() {
-				err = NewStatError(http.StatusForbidden, path)
-			}
-			return
-		}
-		file.Close() // close immediately
+	// files of extensions not exposed are forbidden
+	if !stat.IsDir() && getConfig(ctx).extensionDenied(path) {
+		file.Close()
+		file, stat = nil, nil
+		err = NewStatError(http.StatusForbidden, path)
+	}
+	return
+}
 
-		stats = FileStat{
-			Name:  stat.Name(),
-			Path:  path,
-			Size:  stat.Size(),
-			MTime: stat.ModTime(),
+// readDir reads all the entries of the directory. Entries removed
+// while listed, reported with fs.ErrNotExist along the entries read,
+// are skipped rather than failing the listing.
+func readDir(d http.File) (files []os.FileInfo, err error) {
+	if files, err = d.Readdir(0); errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+// fileError converts the error of opening or reading the file at
+// path to StatError of not found, forbidden, too long or invalid
+// path, symlink loop, or else internal server error
+func fileError(err error, path string) *StatError {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return NewStatError(http.StatusNotFound, path)
+	case errors.Is(err, fs.ErrPermission):
+		return NewStatError(http.StatusForbidden, path)
+	case errors.Is(err, syscall.ENAMETOOLONG):
+		return NewStatError(http.StatusRequestURITooLong, path)
+	case errors.Is(err, fs.ErrInvalid):
+		return NewStatError(http.StatusBadRequest, path)
+	case isSymlinkLoop(err):
+		return NewStatError(http.StatusLoopDetected, path)
+	}
+	return NewStatError(http.StatusInternalServerError, path)
+}
+
+// isHidden reports if any element of the scoped path is a dotfile
+func isHidden(scoped string) bool {
+	for _, name := range strings.Split(scoped, "/") {
+		if strings.HasPrefix(name, ".") {
+			return true
 		}
+	}
+	return false
+}
+
+// childPath returns the scoped path of an entry in the directory
+func childPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// parentPath returns the scoped path of the directory containing
+// the scoped path. The root, and entries directly under it, have
+// the empty string as parent.
+func parentPath(scoped string) string {
+	if parent := path.Dir(scoped); parent != "." {
+		return parent
+	}
+	return ""
+}
+
+// filterFiles filters the files according to the glob, since,
+// minSize and maxSize query of the endpoint and the hidden file and
+// extension policies. The glob pattern is matched against the file
+// name. Sizes bound files only, directories are unaffected.
+func filterFiles(ctx context.Context, files []os.FileInfo) (filtered []os.FileInfo, err error) {
+
+	cfg := getConfig(ctx)
+	query := getEndpointContext(ctx).Query
+	glob := query.Get("glob")
+	if _, err = path.Match(glob, ""); err != nil {
+		return
+	}
+	since, err := querySince(query)
+	if err != nil {
+		return
+	}
+	minSize, err := querySize(query, "minSize")
+	if err != nil {
+		return
+	}
+	maxSize, err := querySize(query, "maxSize")
+	if err != nil {
 		return
 	}
 
-	// for directories
-	if stat.Mode().IsDir() {
-		stats = DirStat{
-			Name:  stat.Name(),
-			Path:  path,
-			MTime: stat.ModTime(),
+	filtered = make([]os.FileInfo, 0, len(files))
+	for _, file := range files {
+		if cfg.hiddenEntry(file) {
+			continue
+		}
+		if !matchGlob(glob, file.Name()) {
+			continue
+		}
+		if modifiedBefore(file, since) {
+			continue
 		}
+		if !file.IsDir() && (minSize >= 0 && file.Size() < minSize || maxSize >= 0 && file.Size() > maxSize) {
+			continue
+		}
+		filtered = append(filtered, file)
+	}
+	return
+}
+
+// validGlob reports if the glob pattern is well formed
+func validGlob(glob string) bool {
+	_, err := path.Match(glob, "")
+	return err == nil
+}
+
+// matchGlob reports if the name matches the glob pattern, any name
+// matching an empty pattern
+func matchGlob(glob, name string) bool {
+	if glob == "" {
+		return true
+	}
+	matched, _ := path.Match(glob, name)
+	return matched
+}
+
+// sortFiles sorts the files according to the sort, order and dirsFirst
+// query of the endpoint. Files are sorted by name first so the order is
+// stable between calls.
+func sortFiles(ctx context.Context, files []os.FileInfo) (err error) {
+
+	query := getEndpointContext(ctx).Query
+	s, order := getEndpointContext(ctx).Sort, query.Get("order")
+	if s == "" {
+		s = "mtime"
+		if order == "" {
+			order = "desc"
+		}
+	}
+
+	// reverse the direction of all sort keys
+	switch order {
+	case "", "asc":
+	case "desc":
+		keys := strings.Split(s, ",")
+		for i, key := range keys {
+			if strings.HasPrefix(key, "-") {
+				keys[i] = key[1:]
+			} else {
+				keys[i] = "-" + key
+			}
+		}
+		s = strings.Join(keys, ",")
+	default:
+		err = fmt.Errorf("unsupported order %#v", order)
 		return
 	}
 
+	// group directories before files
+	if query.Get("dirsFirst") == "true" {
+		s = "type," + s
+	}
+
+	sort.Stable(ByName(files))
+	err = QuerySort(s, files)
 	return
 }
 
-func listEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
-	path := req.(string)
-	if path == "" {
-		path = "."
+// sniffContentType detects the content type of the file from its
+// first 512 bytes, then rewinds the file
+func sniffContentType(file http.File) (contentType string, err error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return
 	}
+	contentType = http.DetectContentType(buf[:n])
+	_, err = file.Seek(0, io.SeekStart)
+	return
+}
 
-	// TODO: build the absolute file / dir path for stat and open
-	stat, err := os.Stat(path)
+// newStat returns DirStat for directories, or FileStat otherwise
+func newStat(ctx context.Context, path string, stat os.FileInfo) interface{} {
+	cfg := getConfig(ctx)
+	ctime, birthtime := fileTimes(stat)
+	var real string
+	if stat.Mode()&os.ModeSymlink != 0 {
+		real = realPath(ctx, path)
+	}
+	if stat.IsDir() {
+		return DirStat{
+			Name:       stat.Name(),
+			Path:       path,
+			Parent:     parentPath(path),
+			MTime:      stat.ModTime(),
+			CTime:      ctime,
+			BirthTime:  birthtime,
+			Mode:       stat.Mode(),
+			TimeFormat: cfg.TimeFormat,
+			Owner:      fileOwner(stat),
+			Access:     statAccess(ctx, path),
+			RealPath:   real,
+			absRoot:    cfg.absRoot,
+		}
+	}
+	dev, ino := fileInode(stat)
+	return FileStat{
+		Name:        stat.Name(),
+		Path:        path,
+		Parent:      parentPath(path),
+		Size:        stat.Size(),
+		MTime:       stat.ModTime(),
+		CTime:       ctime,
+		BirthTime:   birthtime,
+		Mode:        stat.Mode(),
+		ContentType: mime.TypeByExtension(strings.ToLower(filepath.Ext(stat.Name()))),
+		TimeFormat:  cfg.TimeFormat,
+		Owner:       fileOwner(stat),
+		Nlink:       fileNlink(stat),
+		Dev:         dev,
+		Ino:         ino,
+		Access:      statAccess(ctx, path),
+		RealPath:    real,
+		absRoot:     cfg.absRoot,
+	}
+}
 
-	// if file not found
-	if os.IsNotExist(err) {
-		err = NewStatError(http.StatusNotFound, path)
+// statsEndpoint returns FileStat or DirStat of the requested path.
+// An empty path, or one resolved to the root, stats the root directory.
+// A comma separated fields query restricts the JSON to the fields
+// named, ignoring unknown names.
+func statsEndpoint(ctx context.Context, req interface{}) (stats interface{}, err error) {
+
+	path, err := scopePath(req.(string))
+	if err != nil {
 		return
 	}
 
-	// permission problem
-	if err != nil {
-		perr, _ := err.(*os.PathError)
-		if perr.Err.Error() == os.ErrPermission.Error() {
-			err = NewStatError(http.StatusForbidden, path)
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	// stats of symbolic link itself, if not following
+	if getConfig(ctx).NoFollowSymlinks {
+		var isLink bool
+		if stats, isLink, err = linkStat(ctx, path); isLink || err != nil {
+			return
 		}
+	}
+
+	file, stat, err := openFile(ctx, path)
+	if err != nil {
 		return
 	}
+	defer file.Close()
+
+	stats = newStat(ctx, path, stat)
 
-	// for directories
-	if stat.Mode().IsDir() {
+	// real path of the symbolic link followed, if any
+	if real := realPath(ctx, path); real != "" {
+		switch s := stats.(type) {
+		case FileStat:
+			s.RealPath = real
+			stats = s
+		case DirStat:
+			s.RealPath = real
+			stats = s
+		}
+	}
 
-		var d *os.File
-		files := make([]os.FileInfo, 0, 40)
-		// TODO: use FileSystem for file access
-		if d, err = os.Open(path); err != nil {
-			log.Printf("Error listing path %#v:%s", path, err)
-			err = NewStatError(http.StatusInternalServerError, path)
+	// sniff the content type if unknown by extension
+	if fileStats, ok := stats.(FileStat); ok && fileStats.ContentType == "" && stat.Mode().IsRegular() {
+		if fileStats.ContentType, err = sniffContentType(file); err != nil {
 			return
 		}
-		defer d.Close()
+		stats = fileStats
+	}
 
-		files, err = d.Readdir(0)
-		if err != nil {
-			log.Printf("Error listing path %#v:%s", path, err)
+	// number of entries of directory, bounded unless requested in full
+	if dirStats, ok := stats.(DirStat); ok {
+		full := getEndpointContext(ctx).Query.Get("count") == "true"
+		if err = countEntries(ctx, &dirStats, file, full); err != nil {
 			return
 		}
+		stats = dirStats
+	}
 
-		// sort according to query
-		epCtx := getEndpointContext(ctx)
-		s := epCtx.Sort
-		if s == "" {
-			s = "-mtime"
+	// aggregated disk usage of directory, if requested
+	if dirStats, ok := stats.(DirStat); ok && getEndpointContext(ctx).Query.Get("recursive") == "true" {
+		var usage Usage
+		if usage, err = diskUsage(ctx, path); err != nil {
+			return
 		}
-		// TODO: rewrite with go-linq
-		QuerySort(s, files) // TODO: add error reporting here
+		dirStats.Usage = &usage
+		stats = dirStats
+	}
 
-		listLen := len(files)
-		list := make([]FileInfo, listLen)
-		for i := 0; i < listLen; i++ {
-			item := files[i]
+	// checksum of file content, if requested
+	if algorithm := getEndpointContext(ctx).Query.Get("hash"); algorithm != "" && stat.Mode().IsRegular() {
+		if _, ok := hashers[algorithm]; !ok {
+			err = NewStatError(http.StatusBadRequest, path)
+			return
+		}
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		fileStats := stats.(FileStat)
+		if fileStats.Checksum, err = fileChecksum(ctx, path, stat, algorithm, file); err != nil {
+			return
+		}
+		stats = fileStats
+	}
 
-			// parse item URL
-			itemPath := path + "/" + item.Name()
-			if path == "." {
-				itemPath = item.Name()
-			}
+	// strong entity tag from content hash, if configured. hashed again
+	// rather than from the checksum requested, which may be cached.
+	if fileStats, ok := stats.(FileStat); ok && getConfig(ctx).StrongETag && stat.Mode().IsRegular() {
+		if fileStats.contentTag, err = contentETag(ctx, path, stat, file); err != nil {
+			return
+		}
+		stats = fileStats
+	}
 
-			if item.Mode().IsRegular() {
-				list[i] = FileInfo{
-					Name:  item.Name(),
-					Type:  "file",
-					Path:  itemPath,
-					Size:  item.Size(),
-					MTime: item.ModTime(),
-					Links: []Link{
-						{
-							Rel:  "self",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/" + itemPath,
-						},
-						{
-							Rel:  "stat",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/stats/" + itemPath,
-						},
-					},
-				}
-			} else if item.IsDir() {
-				list[i] = FileInfo{
-					Name:  item.Name(),
-					Type:  "directory",
-					Path:  itemPath,
-					MTime: item.ModTime(),
-					Links: []Link{
-						{
-							Rel:  "self",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/" + itemPath,
-						},
-						{
-							Rel:  "stat",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/stats/" + itemPath,
-						},
-						{
-							Rel:  "list",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/lists/" + itemPath,
-						},
-					},
-				}
-			} else {
-				list[i] = FileInfo{
-					Name: item.Name(),
-					Type: "other",
-					Path: itemPath,
-					Links: []Link{
-						{
-							Rel:  "self",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/" + itemPath,
-						},
-						{
-							Rel:  "stat",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/stats/" + itemPath,
-						},
-					},
-				}
+	// only the selected fields, if requested
+	if fields := getEndpointContext(ctx).Query.Get("fields"); fields != "" {
+		stats = selectFields(stats, fields)
+	}
+	return
+}
+
+// countEntries counts the entries of the directory d, omitting hidden
+// ones, up to the configured bound of visible entries unless full.
+// Entries are read in batches until past the bound, so that hidden
+// ones never take its place.
+func countEntries(ctx context.Context, dir *DirStat, d http.File, full bool) (err error) {
+	cfg := getConfig(ctx)
+	bound := cfg.entryCountBound()
+	count := 0
+	for {
+		var files []os.FileInfo
+		if full {
+			files, err = readDir(d)
+		} else if files, err = d.Readdir(bound + 1 - count); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			err = fileError(err, dir.Path)
+			return
+		}
+		for _, file := range files {
+			if !cfg.hiddenEntry(file) {
+				count++
 			}
 		}
+		if full || count > bound || len(files) == 0 {
+			break
+		}
+	}
+	if !full && count > bound {
+		count = bound
+		dir.EntryCountTruncated = true
+	}
+	dir.EntryCount = &count
+	return
+}
 
-		resp = struct {
-			Items []FileInfo `json:"items"`
-		}{
-			Items: list,
+// indexStatsEndpoint returns the stats of the index file of a
+// directory, by the configured IndexFiles, or else the stats of the
+// file or directory
+func indexStatsEndpoint(ctx context.Context, req interface{}) (stats interface{}, err error) {
+	if stats, err = statsEndpoint(ctx, req); err != nil {
+		return
+	}
+	dir, ok := stats.(DirStat)
+	if !ok {
+		return
+	}
+	for _, name := range getConfig(ctx).IndexFiles {
+		index, ierr := statsEndpoint(ctx, childPath(dir.Path, name))
+		if _, isFile := index.(FileStat); ierr == nil && isFile {
+			return index, nil
 		}
+	}
+	return
+}
+
+func listEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
+
+	path, err := scopePath(req.(string))
+	if err != nil {
+		return
+	}
+	d, stat, err := openFile(ctx, path)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	// only directories can be listed
+	if !stat.IsDir() {
+		err = NewStatError(http.StatusBadRequest, path)
 		return
 	}
 
-	err = NewStatError(http.StatusBadRequest, path)
+	files, err := readDir(d)
+	if err != nil {
+		getConfig(ctx).logger().Error("error listing path", "path", path, "error", err)
+		err = fileError(err, path)
+		return
+	}
+
+	// pagination of entries
+	query := getEndpointContext(ctx).Query
+	offset, err := queryInt(query, "offset", 0, 0)
+	if err != nil {
+		err = NewStatError(http.StatusBadRequest, path)
+		return
+	}
+	limit, err := queryInt(query, "limit", -1, 1)
+	if err != nil {
+		err = NewStatError(http.StatusBadRequest, path)
+		return
+	}
+
+	if files, err = filterFiles(ctx, files); err != nil {
+		err = NewStatError(http.StatusBadRequest, path)
+		return
+	}
+	if err = sortFiles(ctx, files); err != nil {
+		err = NewStatError(http.StatusBadRequest, path)
+		return
+	}
+
+	page := &Page{
+		Offset: offset,
+		Limit:  limit,
+		Total:  len(files),
+	}
+	if offset > len(files) {
+		offset = len(files)
+	}
+	files = files[offset:]
+
+	// cap of entries returned, truncating the listing if exceeded
+	if max := getConfig(ctx).MaxEntries; max > 0 && (limit < 0 || limit > max) && len(files) > max {
+		limit = max
+		page.Truncated = true
+	}
+	if limit >= 0 && limit < len(files) {
+		files = files[:limit]
+		next := offset + limit
+		page.Next = &next
+	}
+
+	dir := newStat(ctx, path, stat).(DirStat)
+	dir.Page = page
+	dir.Entries = make([]interface{}, len(files))
+	for i, item := range files {
+		itemPath := childPath(path, item.Name())
+		if getConfig(ctx).EntryErrors {
+			if serr := entryError(ctx, itemPath); serr != nil {
+				dir.Entries[i] = serr
+				continue
+			}
+		}
+		dir.Entries[i] = newStat(ctx, itemPath, item)
+	}
+
+	resp = dir
 	return
 }
 
-func handleEndpoint(endpoint func(ctx context.Context, req interface{}) (resp interface{}, err error)) http.HandlerFunc {
+// entryError opens the entry at path, as its stats or content would
+// be, and returns the error if it cannot be opened
+func entryError(ctx context.Context, path string) *StatError {
+	file, err := getFilesystem(ctx).Open("/" + path)
+	if err != nil {
+		return fileError(err, path)
+	}
+	file.Close()
+	return nil
+}
+
+// queryInt parses the integer query parameter of the given name.
+// Returns def if the parameter is absent, or error if it is not an
+// integer or is less than min.
+func queryInt(query url.Values, name string, def, min int) (value int, err error) {
+	str := query.Get(name)
+	if str == "" {
+		value = def
+		return
+	}
+	if value, err = strconv.Atoi(str); err != nil {
+		return
+	}
+	if value < min {
+		err = fmt.Errorf("%s must not be less than %d", name, min)
+	}
+	return
+}
+
+// querySince parses the RFC3339 time of the since query parameter.
+// Returns the zero time if the parameter is absent.
+func querySince(query url.Values) (since time.Time, err error) {
+	if str := query.Get("since"); str != "" {
+		since, err = time.Parse(time.RFC3339, str)
+	}
+	return
+}
+
+// querySize parses the size query parameter of the given name, in
+// bytes. Returns -1 if the parameter is absent, or error if it is not
+// a non-negative integer.
+func querySize(query url.Values, name string) (size int64, err error) {
+	str := query.Get(name)
+	if str == "" {
+		return -1, nil
+	}
+	if size, err = strconv.ParseInt(str, 10, 64); err == nil && size < 0 {
+		err = fmt.Errorf("%s must not be negative", name)
+	}
+	return
+}
+
+// modifiedBefore reports if the entry is a file modified before since.
+// Directories are never, as their modification time does not change
+// with the files nested.
+func modifiedBefore(file os.FileInfo, since time.Time) bool {
+	return !since.IsZero() && !file.IsDir() && file.ModTime().Before(since)
+}
+
+// writeJSON writes v as the JSON body of the response with the
+// given status code. The body is omitted for HEAD requests.
+func writeJSON(w http.ResponseWriter, r *http.Request, code int, v interface{}) {
+	buf := &bytes.Buffer{}
+	newEncoder(buf, r).Encode(v)
+	writeBody(w, r, code, buf.Bytes(), "", nil)
+}
+
+// newEncoder returns a JSON encoder writing to w, which indents the
+// output for human reading if the request has pretty=true or prefers
+// HTML, as browsers do. The output is compact by default.
+func newEncoder(w io.Writer, r *http.Request) *json.Encoder {
+	enc := json.NewEncoder(w)
+	if acceptPretty(r) {
+		enc.SetIndent("", "  ")
+	}
+	return enc
+}
+
+// acceptPretty reports if the request asks for indented JSON
+func acceptPretty(r *http.Request) bool {
+	if r.URL.Query().Get("pretty") == "true" {
+		return true
+	}
+	return negotiateMedia(r, jsonMediaType, "text/html") == "text/html"
+}
+
+// writeBody writes the JSON body, encoded with the content encoding
+// if not empty, with the given status code. The body is omitted for
+// HEAD requests.
+func writeBody(w http.ResponseWriter, r *http.Request, code int, body []byte, encoding string, encoders map[string]Encoder) {
+	if encoding != "" {
+		compressed, err := compress(encoding, encoders, body)
+		if err == nil {
+			body = compressed
+			w.Header().Set("Content-Encoding", encoding)
+		}
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(code)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// writeStatError writes err as StatError, or as internal server
+// error if it is not a StatError, in the format negotiated
+func writeStatError(w http.ResponseWriter, r *http.Request, err error) {
+	serr, ok := err.(*StatError)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if writeNegotiatedError(w, r, serr.Code, serr.Message(), serr.Path) {
+		return
+	}
+	writeJSON(w, r, serr.Code, serr)
+}
+
+// writeNegotiatedError writes the error as JSON:API, HTML or plain
+// text if the request prefers the format over JSON. It reports if the
+// error is written.
+func writeNegotiatedError(w http.ResponseWriter, r *http.Request, code int, message, path string) bool {
+	w.Header().Add("Vary", "Accept")
+	switch negotiateMedia(r, jsonMediaType, jsonAPIMediaType, "text/html", "text/plain") {
+	case jsonAPIMediaType:
+		writeJSONAPIError(w, r, code, message, path)
+	case "text/html":
+		body := &bytes.Buffer{}
+		if err := renderError(body, code, message, path); err != nil {
+			return false
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		writeBody(w, r, code, body.Bytes(), "", nil)
+	case "text/plain":
+		body := fmt.Sprintf("%d %s\n", code, message)
+		if path != "" {
+			body += path + "\n"
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeBody(w, r, code, []byte(body), "", nil)
+	default:
+		return false
+	}
+	return true
+}
+
+// writeError writes an error message with the given status code, as
+// JSON unless another format is negotiated
+func writeError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	if writeNegotiatedError(w, r, code, message, "") {
+		return
+	}
+	writeJSON(w, r, code, struct {
+		Code    int    `json:"code"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}{
+		Code:    code,
+		Status:  "error",
+		Message: message,
+	})
+}
+
+// allowMethod reports if method is one of the methods
+func allowMethod(methods []string, method string) bool {
+	for _, allowed := range methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// EndpointFunc serves the decoded request of an endpoint. The
+// request of GET and HEAD endpoints is the path of the URL relative
+// to the endpoint. The response is encoded as JSON, or is no content
+// if nil, and errors as StatError.
+type EndpointFunc func(ctx context.Context, req interface{}) (resp interface{}, err error)
+
+// decodeFunc decodes the request of an endpoint from the HTTP request
+type decodeFunc func(r *http.Request) (req interface{}, err error)
+
+// decodePath decodes the request as the path of the URL
+func decodePath(r *http.Request) (interface{}, error) {
+	return r.URL.Path, nil
+}
+
+// withTimeout derives the request context of the handler with the
+// timeout. No timeout if zero.
+func withTimeout(timeout time.Duration, h http.HandlerFunc) http.HandlerFunc {
+	if timeout <= 0 {
+		return h
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		h(w, r.WithContext(ctx))
+	}
+}
 
-		ctx := context.Background()
+// contextError converts err to the status error of the context if
+// the client disconnected, the request timed out or the API shut down
+func contextError(ctx context.Context, err error, path string) error {
+	if errors.Is(context.Cause(ctx), errShutdown) {
+		return NewStatError(http.StatusServiceUnavailable, path)
+	}
+	switch ctx.Err() {
+	case context.Canceled:
+		return NewStatError(StatusClientClosedRequest, path)
+	case context.DeadlineExceeded:
+		return NewStatError(http.StatusGatewayTimeout, path)
+	}
+	return err
+}
 
-		// prepare context
-		if r != nil {
-			ctx = withEndpointContext(ctx, r)
+// limitBody limits the request body of the handler to the size, in
+// bytes, with http.MaxBytesReader. No limit if zero.
+func limitBody(limit int64, h http.HandlerFunc) http.HandlerFunc {
+	if limit <= 0 {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		h(w, r)
+	}
+}
+
+// bodyError converts the error of reading the request body to
+// StatError of request entity too large, if over the limit of
+// limitBody, or else bad request
+func bodyError(err error, path string) *StatError {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return NewStatError(http.StatusRequestEntityTooLarge, path)
+	}
+	return NewStatError(http.StatusBadRequest, path)
+}
+
+// handleEndpoint serves GET and HEAD requests of the endpoint with
+// the URL path as request
+func handleEndpoint(cfg *Config, endpoint EndpointFunc) http.HandlerFunc {
+	return handleEndpointWith(cfg, []string{http.MethodGet, http.MethodHead}, decodePath, endpoint)
+}
+
+// handleEndpointWith serves requests of the allowed methods to the
+// endpoint, decoded with decode
+func handleEndpointWith(cfg *Config, methods []string, decode decodeFunc, endpoint EndpointFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// access log of the request, if enabled
+		if cfg.AccessLog {
+			var done func()
+			w, done = cfg.logAccess(w, r)
+			defer done()
+		}
+
+		// only allowed methods
+		if !allowMethod(methods, r.Method) {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
 		}
 
-		// handle path request
-		resp, err := endpoint(ctx, r.URL.Path)
+		// JSONP callback, if any, is validated before any work
+		callback, err := cfg.jsonpCallback(r)
+		if err != nil {
+			writeStatError(w, r, err)
+			return
+		}
+
+		// prepare context
+		ctx := withFilesystem(withEndpointContext(r.Context(), r), cfg.Root)
+		ctx = withConfig(ctx, cfg)
+
+		// handle decoded request
+		req, err := decode(r)
+		var resp interface{}
+		if err == nil {
+			resp, err = endpoint(ctx, req)
+		}
 
 		// handle error
 		if err != nil {
-			switch serr := err.(type) {
-			case *StatError:
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(serr.Code)
-				jsonw := json.NewEncoder(w)
-				jsonw.Encode(serr)
-			default:
-				statusCode := http.StatusInternalServerError
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(statusCode)
-				jsonw := json.NewEncoder(w)
-				jsonw.Encode(struct {
-					Code    int    `json:"code"`
-					Status  string `json:"status"`
-					Message string `json:"message"`
-				}{
-					Code:    statusCode,
-					Status:  "error",
-					Message: err.Error(),
-				})
+			err = contextError(ctx, err, r.URL.Path)
+			if serr, ok := err.(*StatError); ok && (serr.Code == http.StatusServiceUnavailable || serr.Code == http.StatusGatewayTimeout) {
+				cfg.setRetryAfter(w)
+			}
+			if cfg.CacheControl != "" {
+				w.Header().Set("Cache-Control", "no-store")
 			}
+			writeStatError(w, r, err)
+			return
+		}
+
+		// cache directives of successful reads, if configured
+		if cfg.CacheControl != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+			w.Header().Set("Cache-Control", cfg.CacheControl)
+		}
+
+		// no content in response
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
+		// responses are JSON unless JSON:API is negotiated. listings
+		// may also be streamed as NDJSON, or HTML pages for browsing.
+		offers := []string{jsonMediaType, jsonAPIMediaType}
+		if dir, ok := resp.(DirStat); ok && dir.Page != nil {
+			offers = append(offers, ndjsonMediaType, "text/html")
+		}
+		mediaType := negotiateMedia(r, offers...)
+		if callback != "" {
+			mediaType = jsonMediaType // JSONP wraps plain JSON
+		}
+		ndjson := mediaType == ndjsonMediaType
+		jsonAPI := mediaType == jsonAPIMediaType
+		html := mediaType == "text/html"
+		w.Header().Add("Vary", "Accept")
+
+		// encode response, unless it is a large listing to be
+		// streamed, and negotiate its content encoding
+		streamed := ndjson || (!jsonAPI && !html && callback == "" && largeListing(resp))
+		body := &bytes.Buffer{}
+		if html {
+			if err := renderListing(body, cfg.basePath(r)+cfg.mount, resp.(DirStat)); err != nil {
+				writeError(w, r, http.StatusInternalServerError, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		} else if jsonAPI {
+			doc, err := jsonAPIDocument(resp)
+			if err != nil {
+				writeError(w, r, http.StatusInternalServerError, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", jsonAPIMediaType)
+			newEncoder(body, r).Encode(doc)
+		} else if callback != "" {
+			newEncoder(body, r).Encode(cfg.envelope(resp))
+			body = bytes.NewBuffer(wrapJSONP(callback, body.Bytes()))
+			w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+		} else if !streamed {
+			newEncoder(body, r).Encode(cfg.envelope(resp))
+		}
+		encoding := ""
+		if cfg.CompressThreshold > 0 && !ndjson {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if streamed || body.Len() > cfg.CompressThreshold {
+				encoding = acceptEncoding(r, cfg.Encoders)
+			}
+		}
+
+		// cache validators of file / directory stats
+		if modTime, etag, ok := statValidators(resp); ok {
+			etag = encodedETag(etag, encoding)
+			if ndjson {
+				etag = encodedETag(etag, "ndjson")
+			} else if jsonAPI {
+				etag = encodedETag(etag, "jsonapi")
+			} else if html {
+				etag = encodedETag(etag, "html")
+			}
+			// file systems such as embed.FS have no modification time
+			if !modTime.IsZero() {
+				w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+			}
+			w.Header().Set("ETag", etag)
+			if notModified(r, modTime, etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
 		// handle normal response
-		w.Header().Set("Content-Type", "application/json")
-		jsonw := json.NewEncoder(w)
-		jsonw.Encode(resp)
+		if ndjson {
+			if err := writeNDJSON(w, r, http.StatusOK, resp.(DirStat)); err != nil {
+				cfg.logger().Error("error streaming response", "path", r.URL.Path, "error", err)
+			}
+		} else if streamed {
+			if err := writeStream(w, r, http.StatusOK, resp.(DirStat), encoding, cfg.Encoders, cfg.Envelope); err != nil {
+				cfg.logger().Error("error streaming response", "path", r.URL.Path, "error", err)
+			}
+		} else {
+			writeBody(w, r, http.StatusOK, body.Bytes(), encoding, cfg.Encoders)
+		}
+
+		cfg.logger().Debug("endpoint response", "path", r.URL.Path, "type", fmt.Sprintf("%T", resp))
 
-		log.Printf("resp: %#v", resp)
+		// dump of the response, only for debugging as it can be large
+		if cfg.LogResponses {
+			attrs := []interface{}{"path", r.URL.Path, "type", fmt.Sprintf("%T", resp)}
+			if ndjson || streamed {
+				attrs = append(attrs, "response", fmt.Sprintf("%#v", resp))
+			} else {
+				attrs = append(attrs, "body", body.String())
+			}
+			cfg.logger().Info("endpoint response body", attrs...)
+		}
+	}
+}
+
+// statsMediaType is the media type to request the stats of a file or
+// directory at its normal URL
+const statsMediaType = "application/goserve+json"
+
+// acceptStats reports if the request negotiates the stats of the file
+// or directory, by the Content-Type or Accept header, instead of its
+// content
+func acceptStats(r *http.Request) bool {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == statsMediaType {
+		return true
+	}
+
+	// by explicit Accept, unless another media type is preferred
+	accepted := parseQuality(r.Header.Get("Accept"))
+	q, ok := accepted[statsMediaType]
+	if !ok || q <= 0 {
+		return false
 	}
+	for _, other := range accepted {
+		if other > q {
+			return false
+		}
+	}
+	return true
+}
+
+// endpointInfo describes an endpoint of the API
+type endpointInfo struct {
+	Name        string   `json:"name"`
+	Methods     []string `json:"methods"`
+	Description string   `json:"description"`
+
+	// path is true if the endpoint takes a file / directory path
+	path bool
+
+	// params are the query parameters of the endpoint
+	params []string
+
+	// schema is the name of the response schema, if any
+	schema string
+}
+
+// sortParams are the query parameters to sort listings
+var sortParams = []string{"sort", "order", "dirsFirst", "glob"}
+
+// apiEndpoints are the endpoints advertised by the API
+var apiEndpoints = []endpointInfo{
+	{
+		Name:        "stats",
+		Methods:     []string{"GET", "HEAD", "POST"},
+		Description: "Information about a file or a directory, or a batch of them",
+		path:        true,
+		params:      []string{"paths", "recursive", "hash", "fields", "count"},
+		schema:      "Stat",
+	},
+	{
+		Name:        "list",
+		Methods:     []string{"GET", "HEAD"},
+		Description: "List of files and directories within a directory",
+		path:        true,
+		params:      append([]string{"offset", "limit", "since", "minSize", "maxSize"}, sortParams...),
+		schema:      "DirStat",
+	},
+	{
+		Name:        "tree",
+		Methods:     []string{"GET", "HEAD"},
+		Description: "Recursive tree of files and directories within a directory",
+		path:        true,
+		params:      append([]string{"depth", "since"}, sortParams...),
+		schema:      "DirStat",
+	},
+	{
+		Name:        "summary",
+		Methods:     []string{"GET", "HEAD"},
+		Description: "Aggregate statistics of the entries of a directory, or of its tree if recursive",
+		path:        true,
+		params:      []string{"recursive"},
+		schema:      "Summary",
+	},
+	{
+		Name:        "read",
+		Methods:     []string{"GET", "HEAD"},
+		Description: "Content of a file, with support of range requests",
+		path:        true,
+	},
+	{
+		Name:        "exists",
+		Methods:     []string{"GET", "HEAD"},
+		Description: "Existence of a file or a directory, by status code only",
+		path:        true,
+	},
+	{
+		Name:        "archive",
+		Methods:     []string{"GET", "HEAD"},
+		Description: "Archive of a directory, as zip or tar.gz",
+		path:        true,
+		params:      []string{"format"},
+	},
+	{
+		Name:        "search",
+		Methods:     []string{"GET", "HEAD"},
+		Description: "Entries of a directory tree with names matching a query",
+		path:        true,
+		params:      []string{"q", "match"},
+	},
+	{
+		Name:        "move",
+		Methods:     []string{"POST", "PUT"},
+		Description: "Rename or move a file or a directory, if writes are enabled",
+		params:      []string{"from", "to", "dryRun"},
+		schema:      "Stat",
+	},
+	{
+		Name:        "mkdir",
+		Methods:     []string{"POST"},
+		Description: "Create a directory and its parents, if writes are enabled",
+		path:        true,
+		params:      []string{"dryRun"},
+		schema:      "DirStat",
+	},
+	{
+		Name:        "delete",
+		Methods:     []string{"DELETE"},
+		Description: "Delete a file or a directory, recursively if requested, if writes are enabled",
+		path:        true,
+		params:      []string{"recursive", "dryRun"},
+	},
+	{
+		Name:        "upload",
+		Methods:     []string{"PUT"},
+		Description: "Write the request body to a file, if writes are enabled",
+		path:        true,
+		params:      []string{"dryRun"},
+		schema:      "FileStat",
+	},
+	{
+		Name:        "health",
+		Methods:     []string{"GET", "HEAD"},
+		Description: "Health of the API and its root",
+	},
+	{
+		Name:        "openapi.json",
+		Methods:     []string{"GET", "HEAD"},
+		Description: "OpenAPI description of the API",
+	},
+	{
+		Name:        "graphql",
+		Methods:     []string{"GET", "POST"},
+		Description: "GraphQL query of files and directories",
+	},
 }
 
 // ServeAPI generates a middleware to serve API for file / directory information
-// query
-func ServeAPI(path string, root http.FileSystem) midway.Middleware {
+// query. The API is mounted at root if path is empty or "/".
+func ServeAPI(path string, root http.FileSystem, opts ...Option) midway.Middleware {
+	cfg := Config{
+		Root: root,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return ServeAPIWithConfig(path, cfg)
+}
+
+// ServeFS generates a middleware to serve API for file / directory information
+// query of the fs.FS, such as embed.FS or os.DirFS. The file system is adapted
+// with http.FS, which lists directories with fs.ReadDirFile where implemented.
+func ServeFS(path string, fsys fs.FS, opts ...Option) midway.Middleware {
+	return ServeAPI(path, http.FS(fsys), opts...)
+}
+
+// ServeAPIWithConfig generates a middleware to serve API for file / directory
+// information query with the given config
+func ServeAPIWithConfig(path string, cfg Config) midway.Middleware {
 
 	path = strings.TrimRight(path, "/") // strip trailing slash
 	pathWithSlash := path + "/"
 	pathLen := len(pathWithSlash)
+	cfg.mount = path
+	cfg.checksums = newChecksumCache(cfg.ChecksumCacheSize)
+	cfg.absRoot = cfg.absoluteRoot()
+	cfg.limiter = newRateLimiter(cfg.RateLimit)
+
+	// registry of endpoints by name
+	endpoints := make(map[string]http.HandlerFunc)
+	register := func(name string, h http.HandlerFunc) {
+		endpoints[name] = cfg.Metrics.instrument(name, withTimeout(cfg.timeout(name), h))
+	}
+
+	// built-in endpoints, expensive ones sharing a concurrency limit
+	expensive := newConcurrencyLimit(cfg.MaxExpensive)
+	gateExpensive := func(h http.HandlerFunc) http.HandlerFunc {
+		return cfg.Shutdown.gate(&cfg, expensive.gate(&cfg, h))
+	}
+	handleStats := handleEndpoint(&cfg, statsEndpoint)
+	handleExpensiveStats := gateExpensive(handleStats)
+	handleBatchStats := limitBody(cfg.maxBodySize(), handleEndpointWith(&cfg, []string{http.MethodGet, http.MethodHead, http.MethodPost}, decodeBatchStats, batchStatsEndpoint))
+	handleExpensiveBatchStats := gateExpensive(handleBatchStats)
+	handleList := handleEndpoint(&cfg, listEndpoint)
+	handleIndexStats := handleEndpoint(&cfg, indexStatsEndpoint)
+	handleExpensiveIndexStats := gateExpensive(handleIndexStats)
+
+	// stats at the URL of files, served as the stats endpoint
+	serveIndexStats := cfg.Metrics.instrument("stats", withTimeout(cfg.timeout("stats"), func(w http.ResponseWriter, r *http.Request) {
+		if expensiveStats(r) {
+			handleExpensiveIndexStats(w, r)
+			return
+		}
+		handleIndexStats(w, r)
+	}))
+	register("stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" && (r.Method == http.MethodPost || r.URL.Query().Get("paths") != "") {
+			if expensiveStats(r) {
+				handleExpensiveBatchStats(w, r)
+				return
+			}
+			handleBatchStats(w, r)
+			return
+		}
+		if expensiveStats(r) {
+			handleExpensiveStats(w, r)
+			return
+		}
+		handleStats(w, r)
+	})
+	register("list", handleList)
+	register("lists", handleList) // legacy alias of list
+	register("tree", gateExpensive(handleEndpoint(&cfg, treeEndpoint)))
+	handleSummary := handleEndpoint(&cfg, summaryEndpoint)
+	handleRecursiveSummary := gateExpensive(handleSummary)
+	register("summary", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("recursive") == "true" {
+			handleRecursiveSummary(w, r)
+			return
+		}
+		handleSummary(w, r)
+	})
+	register("read", handleRead(&cfg))
+	register("exists", handleExists(&cfg))
+	register("archive", gateExpensive(handleArchive(&cfg)))
+	register("search", gateExpensive(handleEndpoint(&cfg, searchEndpoint)))
+	register("health", handleHealth(&cfg))
+	register("move", limitBody(cfg.maxBodySize(), handleEndpointWith(&cfg, []string{http.MethodPost, http.MethodPut}, decodeMove, moveEndpoint)))
+	register("mkdir", handleEndpointWith(&cfg, []string{http.MethodPost}, decodePath, mkdirEndpoint))
+	register("delete", handleEndpointWith(&cfg, []string{http.MethodDelete}, decodePath, deleteEndpoint))
+	register("upload", limitBody(cfg.MaxUploadSize, handleEndpointWith(&cfg, []string{http.MethodPut}, decodeUpload, uploadEndpoint)))
+	handleGraphQL := limitBody(cfg.maxBodySize(), GraphQLHandler().ServeHTTP)
 
-	// wrap endpoints
-	handleStats := handleEndpoint(statsEndpoint)
-	handleList := handleEndpoint(listEndpoint)
-	handleGraphQL := GraphQLHandler()
+	// custom endpoints
+	infos := make([]endpointInfo, 0, len(apiEndpoints)+len(cfg.endpoints))
+	for _, info := range apiEndpoints {
+		if !cfg.hasEndpoint(info.Name) {
+			infos = append(infos, info)
+		}
+	}
+	for _, custom := range cfg.endpoints {
+		register(custom.name, handleEndpoint(&cfg, custom.endpoint))
+		infos = append(infos, endpointInfo{
+			Name:    custom.name,
+			Methods: []string{"GET", "HEAD"},
+			path:    true,
+		})
+	}
+	register("openapi.json", handleOpenAPI(path, infos))
 
 	return func(inner http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-			// serve API endpoint
-			if r.URL.Path == path {
-				http.Redirect(w, r, pathWithSlash, http.StatusMovedPermanently)
+			// cross-origin requests
+			if r.URL.Path == path || strings.HasPrefix(r.URL.Path, pathWithSlash) {
+				if cfg.CORS.handle(w, r) {
+					return
+				}
+				if cfg.limiter.limit(w, r) || cfg.authorize(w, r) {
+					return
+				}
+			}
+
+			// serve API endpoint. no redirect of the base path if
+			// mounted at root, as there is no path without slash
+			if path != "" && r.URL.Path == path {
+				target := cfg.basePath(r) + pathWithSlash
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
 				return
 			}
 			if r.URL.Path == path+"/graphql" {
-				graphCtx := withFilesystem(withEndpointContext(r.Context(), r), root)
-				handleGraphQL.ServeHTTP(w, r.WithContext(graphCtx))
+				graphCtx := withFilesystem(withEndpointContext(r.Context(), r), cfg.Root)
+				graphCtx = withConfig(graphCtx, &cfg)
+				handleGraphQL(w, r.WithContext(graphCtx))
 				return
 			}
 			if strings.HasPrefix(r.URL.Path, pathWithSlash) {
-				r.URL.Path = strings.TrimRight(r.URL.Path[pathLen:], "/") // strip base path
 
-				// stats of file / directory
-				if strings.HasPrefix(r.URL.Path, "stats/") {
-					r.URL.Path = r.URL.Path[6:]
-					handleStats(w, r)
-					return
-				}
+				r.URL.Path = strings.Trim(r.URL.Path[pathLen:], "/") // strip base path and extra slashes
 
-				// listing files in directory
-				if strings.HasPrefix(r.URL.Path, "lists/") {
-					r.URL.Path = r.URL.Path[6:]
-					handleList(w, r)
+				// capability probe of the API
+				if r.URL.Path == "" && r.Method == http.MethodOptions {
+					w.Header().Set("Allow", "OPTIONS")
+					writeJSON(w, r, http.StatusOK, struct {
+						Endpoints []endpointInfo `json:"endpoints"`
+					}{
+						Endpoints: infos,
+					})
 					return
 				}
-				if r.URL.Path == "lists" {
-					r.URL.Path = r.URL.Path[5:]
-					handleList(w, r)
+
+				// endpoint by name, with the rest of path as its path
+				endpoint, rest, _ := strings.Cut(r.URL.Path, "/")
+				if h, ok := endpoints[endpoint]; ok {
+					r.URL.Path = rest
+					h(w, r)
 					return
 				}
 
 				// if no matching endpoint
-				statusCode := http.StatusNotFound
-				w.Header().Add("Content-Type", "application/json")
-				w.WriteHeader(statusCode)
-				jsonw := json.NewEncoder(w)
-				jsonw.Encode(struct {
-					Code    int    `json:"code"`
-					Status  string `json:"status"`
-					Message string `json:"message"`
+				message := fmt.Sprintf("%#v is not a valid API endpoint", endpoint)
+				if writeNegotiatedError(w, r, http.StatusNotFound, message, "") {
+					return
+				}
+				writeJSON(w, r, http.StatusNotFound, struct {
+					Code     int    `json:"code"`
+					Status   string `json:"status"`
+					Endpoint string `json:"endpoint"`
+					Message  string `json:"message"`
 				}{
-					Code:    statusCode,
-					Status:  "error",
-					Message: "not a valid API endpoint",
+					Code:     http.StatusNotFound,
+					Status:   "error",
+					Endpoint: endpoint,
+					Message:  message,
 				})
-
 				return
 			}
 			// server file / directory info query at the URL
-			if r.Header.Get("Content-Type") == "application/goserve+json" {
-				// TODO: also detect the request content-type: "goserve+json/application"
-				// and return file info
+			w.Header().Add("Vary", "Accept")
+			if acceptStats(r) {
+				if cfg.limiter.limit(w, r) || cfg.authorize(w, r) {
+					return
+				}
+				r.URL.Path = strings.TrimLeft(r.URL.Path, "/")
+				serveIndexStats(w, r)
+				return
 			}
 
 			// defers to inner handler
@@ -0,0 +1,781 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+const testRoot = "./testdata/root"
+
+// serveAPI sends a GET request to the API middleware mounted
+// at "/api" with the given root and returns the recorded response
+func serveAPI(root http.FileSystem, target string) *httptest.ResponseRecorder {
+	return serveRequest(root, httptest.NewRequest("GET", target, nil))
+}
+
+// serveRequest sends the request to the API middleware mounted
+// at "/api" with the given root and returns the recorded response
+func serveRequest(root http.FileSystem, req *http.Request, opts ...api.Option) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	api.ServeAPI("/api", root, opts...)(http.NotFoundHandler()).ServeHTTP(w, req)
+	return w
+}
+
+// decodeBody decodes the JSON body of a recorded response
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) (body map[string]interface{}) {
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error decoding response body %#v: %s", w.Body.String(), err)
+	}
+	return
+}
+
+func TestStatsEndpoint(t *testing.T) {
+
+	tests := []struct {
+		target   string
+		code     int
+		typ      string
+		name     string
+		path     string
+		hasSize  bool
+		sizeWant float64
+	}{
+		{"/api/stats/hello.txt", http.StatusOK, "file", "hello.txt", "/hello.txt", true, 6},
+		{"/api/stats/folder", http.StatusOK, "directory", "folder", "/folder", false, 0},
+		{"/api/stats/folder/nested.txt", http.StatusOK, "file", "nested.txt", "/folder/nested.txt", true, 7},
+	}
+
+	for _, test := range tests {
+		w := serveAPI(http.Dir(testRoot), test.target)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := test.typ, body["type"]; want != have {
+			t.Errorf("%s: expected type %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.name, body["name"]; want != have {
+			t.Errorf("%s: expected name %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.path, body["path"]; want != have {
+			t.Errorf("%s: expected path %#v, got %#v", test.target, want, have)
+		}
+		if test.hasSize {
+			if want, have := test.sizeWant, body["size"]; want != have {
+				t.Errorf("%s: expected size %#v, got %#v", test.target, want, have)
+			}
+		}
+	}
+}
+
+func TestStatsEndpoint_scoped(t *testing.T) {
+
+	// restful.go exists in the working directory of the test,
+	// but not in the root file system served
+	w := serveAPI(http.Dir(testRoot), "/api/stats/restful.go")
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	body := decodeBody(t, w)
+	if want, have := "restful.go", body["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+}
+
+func TestStatsEndpoint_duplicateSlashes(t *testing.T) {
+
+	want := serveAPI(http.Dir(testRoot), "/api/stats/folder/nested.txt")
+	if want.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, want.Code)
+	}
+
+	for _, target := range []string{
+		"/api/stats//folder//nested.txt",
+		"/api/stats/folder///nested.txt",
+		"/api//stats//folder/nested.txt",
+	} {
+		have := serveAPI(http.Dir(testRoot), target)
+		if want, have := want.Code, have.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+		if want, have := want.Body.String(), have.Body.String(); want != have {
+			t.Errorf("%s: expected body %s, got %s", target, want, have)
+		}
+	}
+}
+
+func TestStatsEndpoint_traversal(t *testing.T) {
+
+	tests := []struct {
+		desc   string
+		target string
+		code   int
+	}{
+		{"parent of root", "/api/stats/..", http.StatusForbidden},
+		{"dot-dot prefix", "/api/stats/../restful.go", http.StatusForbidden},
+		{"deep dot-dot", "/api/stats/../../../../etc/shadow", http.StatusForbidden},
+		{"encoded dot-dot", "/api/stats/%2e%2e/restful.go", http.StatusForbidden},
+		{"encoded slash", "/api/stats/..%2frestful.go", http.StatusForbidden},
+		{"dot-dot after folder", "/api/stats/folder/../../restful.go", http.StatusForbidden},
+		{"absolute path", "/api/stats//etc/passwd", http.StatusNotFound},
+		{"null byte", "/api/stats/hello.txt%00", http.StatusBadRequest},
+		{"dot-dot within root", "/api/stats/folder/../hello.txt", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := serveAPI(http.Dir(testRoot), test.target)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.desc, want, have)
+		}
+	}
+}
+
+func TestListEndpoint(t *testing.T) {
+
+	w := serveAPI(http.Dir(testRoot), "/api/list/?sort=name")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+
+	body := decodeBody(t, w)
+	if want, have := "directory", body["type"]; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+	entries, ok := body["entries"].([]interface{})
+	if !ok {
+		t.Fatalf("expected entries to be an array, got %#v", body["entries"])
+	}
+	if want, have := 2, len(entries); want != have {
+		t.Fatalf("expected %d entries, got %d", want, have)
+	}
+
+	expected := []struct {
+		name string
+		typ  string
+		path string
+	}{
+		{"folder", "directory", "/folder"},
+		{"hello.txt", "file", "/hello.txt"},
+	}
+	for i, exp := range expected {
+		entry := entries[i].(map[string]interface{})
+		if want, have := exp.name, entry["name"]; want != have {
+			t.Errorf("entries[%d]: expected name %#v, got %#v", i, want, have)
+		}
+		if want, have := exp.typ, entry["type"]; want != have {
+			t.Errorf("entries[%d]: expected type %#v, got %#v", i, want, have)
+		}
+		if want, have := exp.path, entry["path"]; want != have {
+			t.Errorf("entries[%d]: expected path %#v, got %#v", i, want, have)
+		}
+	}
+}
+
+func TestListEndpoint_empty(t *testing.T) {
+
+	w := serveAPI(http.Dir(t.TempDir()), "/api/list/")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+
+	body := decodeBody(t, w)
+	entries, ok := body["entries"].([]interface{})
+	if !ok {
+		t.Fatalf("expected entries to be an array, got %#v", body["entries"])
+	}
+	if want, have := 0, len(entries); want != have {
+		t.Errorf("expected %d entries, got %d", want, have)
+	}
+}
+
+func TestListEndpoint_file(t *testing.T) {
+	w := serveAPI(http.Dir(testRoot), "/api/list/hello.txt")
+	if want, have := http.StatusBadRequest, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestHandleEndpoint_head(t *testing.T) {
+
+	for _, target := range []string{
+		"/api/stats/hello.txt",
+		"/api/list/folder",
+		"/api/stats/not-found",
+	} {
+		get := serveAPI(http.Dir(testRoot), target)
+		head := serveRequest(http.Dir(testRoot), httptest.NewRequest("HEAD", target, nil))
+
+		if want, have := get.Code, head.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+		for _, key := range []string{"Content-Type", "Content-Length"} {
+			if want, have := get.Header().Get(key), head.Header().Get(key); want != have {
+				t.Errorf("%s: expected %s %#v, got %#v", target, key, want, have)
+			}
+		}
+		if want, have := strconv.Itoa(get.Body.Len()), head.Header().Get("Content-Length"); want != have {
+			t.Errorf("%s: expected Content-Length %#v, got %#v", target, want, have)
+		}
+		if want, have := 0, head.Body.Len(); want != have {
+			t.Errorf("%s: expected empty body, got %#v", target, head.Body.String())
+		}
+	}
+}
+
+func TestHandleEndpoint_methodNotAllowed(t *testing.T) {
+
+	for _, method := range []string{"POST", "PUT", "DELETE"} {
+		req := httptest.NewRequest(method, "/api/stats/hello.txt", nil)
+		w := serveRequest(http.Dir(testRoot), req)
+		if want, have := http.StatusMethodNotAllowed, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", method, want, have)
+		}
+		if want, have := "GET, HEAD", w.Header().Get("Allow"); want != have {
+			t.Errorf("%s: expected Allow %#v, got %#v", method, want, have)
+		}
+		body := decodeBody(t, w)
+		if want, have := "error", body["status"]; want != have {
+			t.Errorf("%s: expected status %#v, got %#v", method, want, have)
+		}
+		if want, have := float64(http.StatusMethodNotAllowed), body["code"]; want != have {
+			t.Errorf("%s: expected code %#v, got %#v", method, want, have)
+		}
+	}
+}
+
+func TestStatsEndpoint_mode(t *testing.T) {
+
+	root := t.TempDir()
+	tests := []struct {
+		name string
+		mode os.FileMode
+		want string
+		perm string
+	}{
+		{"private", 0600, "0600", "-rw-------"},
+		{"public", 0644, "0644", "-rw-r--r--"},
+		{"script", 0755, "0755", "-rwxr-xr-x"},
+	}
+
+	for _, test := range tests {
+		file := filepath.Join(root, test.name)
+		if err := os.WriteFile(file, []byte(test.name), 0600); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.Chmod(file, test.mode); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		body := decodeBody(t, serveAPI(http.Dir(root), "/api/stats/"+test.name))
+		if want, have := test.want, body["mode"]; want != have {
+			t.Errorf("%s: expected mode %#v, got %#v", test.name, want, have)
+		}
+		if want, have := test.perm, body["perm"]; want != have {
+			t.Errorf("%s: expected perm %#v, got %#v", test.name, want, have)
+		}
+		if _, ok := body["isSymlink"]; ok {
+			t.Errorf("%s: expected isSymlink to be omitted", test.name)
+		}
+	}
+}
+
+func TestListEndpoint_symlink(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "target"), []byte("target"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Symlink("target", filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlink not supported: %s", err)
+	}
+
+	body := decodeBody(t, serveAPI(http.Dir(root), "/api/list/?sort=name"))
+	entries := body["entries"].([]interface{})
+	link := entries[0].(map[string]interface{})
+	if want, have := "link", link["name"]; want != have {
+		t.Fatalf("expected name %#v, got %#v", want, have)
+	}
+	if want, have := true, link["isSymlink"]; want != have {
+		t.Errorf("expected isSymlink %#v, got %#v", want, have)
+	}
+}
+
+func TestStatsEndpoint_parent(t *testing.T) {
+
+	tests := []struct {
+		target string
+		parent string
+	}{
+		{"/api/stats/folder/nested.txt", "/folder"},
+		{"/api/stats/hello.txt", "/"},
+		{"/api/tree/?depth=0", ""},
+	}
+
+	for _, test := range tests {
+		body := decodeBody(t, serveAPI(http.Dir(testRoot), test.target))
+		if want, have := test.parent, body["parent"]; want != have {
+			t.Errorf("%s: expected parent %#v, got %#v", test.target, want, have)
+		}
+	}
+}
+
+func TestListEndpoint_paths(t *testing.T) {
+
+	body := decodeBody(t, serveAPI(http.Dir(testRoot), "/api/list/folder"))
+	if want, have := "/folder", body["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+	if want, have := "/", body["parent"]; want != have {
+		t.Errorf("expected parent %#v, got %#v", want, have)
+	}
+	nested := entryNamed(t, entriesOf(t, body), "nested.txt")
+	if want, have := "/folder/nested.txt", nested["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+	if want, have := "/folder", nested["parent"]; want != have {
+		t.Errorf("expected parent %#v, got %#v", want, have)
+	}
+
+	// the root has no parent
+	body = decodeBody(t, serveAPI(http.Dir(testRoot), "/api/list/"))
+	if want, have := "/", body["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+	if want, have := "", body["parent"]; want != have {
+		t.Errorf("expected parent %#v, got %#v", want, have)
+	}
+}
+
+func TestWithAbsolutePaths(t *testing.T) {
+
+	abs, err := filepath.Abs(testRoot)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tests := []struct {
+		desc   string
+		opts   []api.Option
+		path   string
+		parent string
+	}{
+		{"relative by default", nil, "/folder/nested.txt", "/folder"},
+		{"relative", []api.Option{api.WithAbsolutePaths(false)}, "/folder/nested.txt", "/folder"},
+		{"absolute", []api.Option{api.WithAbsolutePaths(true)}, filepath.Join(abs, "folder", "nested.txt"), filepath.Join(abs, "folder")},
+	}
+	for _, test := range tests {
+		body := decodeBody(t, serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/list/folder", nil), test.opts...))
+		nested := entryNamed(t, entriesOf(t, body), "nested.txt")
+		if want, have := test.path, nested["path"]; want != have {
+			t.Errorf("%s: expected path %#v, got %#v", test.desc, want, have)
+		}
+		if want, have := test.parent, nested["parent"]; want != have {
+			t.Errorf("%s: expected parent %#v, got %#v", test.desc, want, have)
+		}
+		if want, have := test.parent, body["path"]; want != have {
+			t.Errorf("%s: expected directory path %#v, got %#v", test.desc, want, have)
+		}
+	}
+
+	// the root still has no parent
+	body := decodeBody(t, serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/stats/", nil), api.WithAbsolutePaths(true)))
+	if want, have := abs, body["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+	if want, have := "", body["parent"]; want != have {
+		t.Errorf("expected parent %#v, got %#v", want, have)
+	}
+
+	// relative for roots other than http.Dir
+	body = decodeBody(t, serveRequest(http.FS(os.DirFS(testRoot)), httptest.NewRequest("GET", "/api/stats/hello.txt", nil), api.WithAbsolutePaths(true)))
+	if want, have := "/hello.txt", body["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+}
+
+func TestStatsEndpoint_contentType(t *testing.T) {
+
+	root := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	files := map[string][]byte{
+		"data.json": []byte(`{"hello": "world"}`),
+		"image.png": png,
+		"binary":    {0x00, 0x01, 0x02, 0x03, 0xfe, 0xff},
+		"unknown":   png,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), content, 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+	}{
+		{"data.json", "application/json"},
+		{"image.png", "image/png"},
+		{"binary", "application/octet-stream"},
+		{"unknown", "image/png"},
+	}
+	for _, test := range tests {
+		body := decodeBody(t, serveAPI(http.Dir(root), "/api/stats/"+test.name))
+		if want, have := test.contentType, body["contentType"]; want != have {
+			t.Errorf("%s: expected contentType %#v, got %#v", test.name, want, have)
+		}
+	}
+
+	// sniffing does not affect the checksum
+	body := decodeBody(t, serveAPI(http.Dir(root), "/api/stats/binary?hash=md5"))
+	if want, have := "03d7c0cbcad34b0bcace4967ca60a08c", body["checksum"].(map[string]interface{})["digest"]; want != have {
+		t.Errorf("expected digest %#v, got %#v", want, have)
+	}
+
+	// omitted for directories
+	body = decodeBody(t, serveAPI(http.Dir(testRoot), "/api/stats/folder"))
+	if _, ok := body["contentType"]; ok {
+		t.Errorf("expected contentType to be omitted for directories")
+	}
+}
+
+// errorFileSystem fails to open any file with err
+type errorFileSystem struct {
+	err error
+}
+
+func (fs errorFileSystem) Open(name string) (http.File, error) {
+	return nil, fs.err
+}
+
+func TestStatsEndpoint_openError(t *testing.T) {
+
+	fs := errorFileSystem{err: errors.New("not a path error")}
+	for _, target := range []string{"/api/stats/hello.txt", "/api/list/", "/api/tree/"} {
+		w := serveAPI(fs, target)
+		if want, have := http.StatusInternalServerError, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+		if want, have := "error", decodeBody(t, w)["status"]; want != have {
+			t.Errorf("%s: expected status %#v, got %#v", target, want, have)
+		}
+	}
+}
+
+func TestStatsEndpoint_wrappedPermission(t *testing.T) {
+
+	fs := errorFileSystem{err: fmt.Errorf("wrapped: %w", os.ErrPermission)}
+	for _, target := range []string{"/api/stats/hello.txt", "/api/list/", "/api/tree/", "/api/read/hello.txt"} {
+		w := serveAPI(fs, target)
+		if want, have := http.StatusForbidden, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+	}
+}
+
+func TestListEndpoint_forbidden(t *testing.T) {
+
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "forbidden")
+	if err := os.Mkdir(dir, 0000); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	for _, target := range []string{"/api/list/forbidden", "/api/tree/forbidden", "/api/tree/"} {
+		w := serveAPI(http.Dir(root), target)
+		if want, have := http.StatusForbidden, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+	}
+}
+
+func TestStatsEndpoint_root(t *testing.T) {
+	for _, target := range []string{"/api/stats", "/api/stats/", "/api/stats/.", "/api/stats/folder/.."} {
+		w := serveAPI(http.Dir(testRoot), target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := "directory", body["type"]; want != have {
+			t.Errorf("%s: expected type %#v, got %#v", target, want, have)
+		}
+		if want, have := "/", body["path"]; want != have {
+			t.Errorf("%s: expected path %#v, got %#v", target, want, have)
+		}
+	}
+}
+
+func TestServeAPI_options(t *testing.T) {
+
+	w := serveRequest(http.Dir(testRoot), httptest.NewRequest("OPTIONS", "/api/", nil))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+
+	endpoints, ok := decodeBody(t, w)["endpoints"].([]interface{})
+	if !ok {
+		t.Fatalf("expected endpoints in response")
+	}
+	names := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		endpoint := endpoint.(map[string]interface{})
+		names[endpoint["name"].(string)] = true
+		if _, ok := endpoint["methods"].([]interface{}); !ok {
+			t.Errorf("expected methods of endpoint %#v", endpoint["name"])
+		}
+	}
+	for _, name := range []string{"stats", "list", "tree", "graphql"} {
+		if !names[name] {
+			t.Errorf("expected endpoint %#v to be advertised", name)
+		}
+	}
+}
+
+func TestServeAPI_negotiateStats(t *testing.T) {
+
+	handler := api.ServeAPI("/api", http.Dir(testRoot))(http.FileServer(http.Dir(testRoot)))
+	tests := []struct {
+		name   string
+		header string
+		value  string
+	}{
+		{"content type", "Content-Type", "application/goserve+json"},
+		{"accept", "Accept", "text/html;q=0.9, application/goserve+json"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/hello.txt", nil)
+		req.Header.Set(test.header, test.value)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := "file", body["type"]; want != have {
+			t.Errorf("%s: expected type %#v, got %#v", test.name, want, have)
+		}
+		if want, have := "/hello.txt", body["path"]; want != have {
+			t.Errorf("%s: expected path %#v, got %#v", test.name, want, have)
+		}
+	}
+
+	// without negotiation, the file itself is served
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/hello.txt", nil))
+	if want, have := "hello\n", w.Body.String(); want != have {
+		t.Errorf("expected file content %#v, got %#v", want, have)
+	}
+	if want, have := "Accept", w.Header().Get("Vary"); want != have {
+		t.Errorf("expected Vary %#v, got %#v", want, have)
+	}
+}
+
+func TestServeAPI_rootMount(t *testing.T) {
+
+	for _, base := range []string{"", "/"} {
+		handler := api.ServeAPI(base, http.Dir(testRoot))(http.NotFoundHandler())
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/stats/hello.txt", nil))
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%#v: expected status %d, got %d", base, want, have)
+			continue
+		}
+		if want, have := "/hello.txt", decodeBody(t, w)["path"]; want != have {
+			t.Errorf("%#v: expected path %#v, got %#v", base, want, have)
+		}
+
+		// the root itself is not redirected
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code == http.StatusMovedPermanently {
+			t.Errorf("%#v: unexpected redirect to %#v", base, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestServeAPI_redirectQuery(t *testing.T) {
+	w := serveAPI(http.Dir(testRoot), "/api?foo=bar")
+	if want, have := http.StatusMovedPermanently, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := "/api/?foo=bar", w.Header().Get("Location"); want != have {
+		t.Errorf("expected Location %#v, got %#v", want, have)
+	}
+}
+
+func TestServeAPI_unknownEndpoint(t *testing.T) {
+	w := serveAPI(http.Dir(testRoot), "/api/bogus/x")
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	body := decodeBody(t, w)
+	if want, have := "bogus", body["endpoint"]; want != have {
+		t.Errorf("expected endpoint %#v, got %#v", want, have)
+	}
+	if message, _ := body["message"].(string); !strings.Contains(message, "bogus") {
+		t.Errorf("expected message to name the endpoint, got %#v", body["message"])
+	}
+}
+
+func TestServeAPI_escapedPath(t *testing.T) {
+
+	// percent-encoding is decoded, a literal percent sign included
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/api/stats/hello%2Etxt", http.StatusOK},
+		{"/api/stats/folder%2Fnested.txt", http.StatusOK},
+		{"/api/stats/%25zz", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := serveAPI(http.Dir(testRoot), test.target)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+		}
+	}
+}
+
+func TestHandleEndpoint_pretty(t *testing.T) {
+
+	w := serveAPI(http.Dir(testRoot), "/api/stats/hello.txt")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if body := strings.TrimSuffix(w.Body.String(), "\n"); strings.Contains(body, "\n") {
+		t.Errorf("expected compact output, got %#v", body)
+	}
+
+	w = serveAPI(http.Dir(testRoot), "/api/stats/hello.txt?pretty=true")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "\n  \"") {
+		t.Errorf("expected indented output, got %#v", body)
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats/hello.txt", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	w = serveRequest(http.Dir(testRoot), req)
+	if body := w.Body.String(); !strings.Contains(body, "\n  \"") {
+		t.Errorf("expected indented output for HTML Accept, got %#v", body)
+	}
+}
+
+func TestStatsEndpoint_negotiatedError(t *testing.T) {
+
+	tests := []struct {
+		accept      string
+		contentType string
+		contains    string
+	}{
+		{"", "application/json", `"message":"Not Found"`},
+		{"application/json, text/plain;q=0.5", "application/json", `"message":"Not Found"`},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", "<h1>404 Not Found</h1>"},
+		{"text/plain", "text/plain; charset=utf-8", "404 Not Found\nmissing.txt\n"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/api/stats/missing.txt", nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		w := serveRequest(http.Dir(testRoot), req)
+		if want, have := http.StatusNotFound, w.Code; want != have {
+			t.Errorf("Accept %#v: expected status %d, got %d", test.accept, want, have)
+		}
+		if want, have := test.contentType, w.Header().Get("Content-Type"); want != have {
+			t.Errorf("Accept %#v: expected Content-Type %#v, got %#v", test.accept, want, have)
+		}
+		if body := w.Body.String(); !strings.Contains(body, test.contains) {
+			t.Errorf("Accept %#v: expected body to contain %#v, got %#v", test.accept, test.contains, body)
+		}
+	}
+}
+
+func TestStatsEndpoint_longPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows supports long paths")
+	}
+
+	// longer than PATH_MAX of most platforms
+	target := "/api/stats/" + strings.Repeat("folder/", 600) + "hello.txt"
+	w := serveAPI(http.Dir(testRoot), target)
+	if want, have := http.StatusRequestURITooLong, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// a single name longer than NAME_MAX
+	w = serveAPI(http.Dir(testRoot), "/api/stats/"+strings.Repeat("a", 300))
+	if want, have := http.StatusRequestURITooLong, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestServeAPI_negotiateIndex(t *testing.T) {
+
+	root := t.TempDir()
+	for _, dir := range []string{"site", "plain", "nested/index.html"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "site", "index.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	handler := api.ServeAPI("/api", http.Dir(root), api.WithIndexFiles("index.html", "index.json"))(http.NotFoundHandler())
+	tests := []struct {
+		target string
+		typ    string
+		path   string
+	}{
+		{"/site/", "file", "/site/index.json"},
+		{"/plain/", "directory", "/plain"},
+		{"/nested/", "directory", "/nested"}, // index.html is a directory
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		req.Header.Set("Accept", "application/goserve+json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := test.typ, body["type"]; want != have {
+			t.Errorf("%s: expected type %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.path, body["path"]; want != have {
+			t.Errorf("%s: expected path %#v, got %#v", test.target, want, have)
+		}
+	}
+
+	// the stats endpoint is not affected
+	w := serveRequest(http.Dir(root), httptest.NewRequest("GET", "/api/stats/site", nil), api.WithIndexFiles("index.json"))
+	if want, have := "directory", decodeBody(t, w)["type"]; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+}
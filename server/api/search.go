@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// defaultMaxSearchResults bounds the results of a search if not
+// configured
+const defaultMaxSearchResults = 1000
+
+// SearchResult is the response of the search endpoint
+type SearchResult struct {
+	Query string
+
+	// Entries matching the query, each FileStat or DirStat
+	Entries []interface{}
+
+	// Truncated is true if the results are bounded by the maximum
+	Truncated bool
+}
+
+// MarshalJSON implements encoding/json.Marshaler
+func (result SearchResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Query     string        `json:"query"`
+		Entries   []interface{} `json:"entries"`
+		Truncated bool          `json:"truncated,omitempty"`
+	}{
+		Query:     result.Query,
+		Entries:   result.Entries,
+		Truncated: result.Truncated,
+	})
+}
+
+// searchEndpoint walks the subtree of the path for entries with names
+// matching the q query, as a substring, or as a glob pattern if the
+// match query is "glob". Symlinked directories are not followed.
+func searchEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
+
+	scoped, err := scopePath(req.(string))
+	if err != nil {
+		return
+	}
+
+	query := getEndpointContext(ctx).Query
+	q := query.Get("q")
+	if q == "" {
+		err = NewStatError(http.StatusBadRequest, scoped)
+		return
+	}
+	var match func(name string) bool
+	switch query.Get("match") {
+	case "", "substring":
+		match = func(name string) bool {
+			return strings.Contains(name, q)
+		}
+	case "glob":
+		if _, err = path.Match(q, ""); err != nil {
+			err = NewStatError(http.StatusBadRequest, scoped)
+			return
+		}
+		match = func(name string) bool {
+			matched, _ := path.Match(q, name)
+			return matched
+		}
+	default:
+		err = NewStatError(http.StatusBadRequest, scoped)
+		return
+	}
+
+	d, stat, err := openFile(ctx, scoped)
+	if err != nil {
+		return
+	}
+	d.Close()
+	if !stat.IsDir() {
+		err = NewStatError(http.StatusBadRequest, scoped)
+		return
+	}
+
+	result := SearchResult{
+		Query:   q,
+		Entries: make([]interface{}, 0),
+	}
+	max := getConfig(ctx).MaxSearchResults
+	if max <= 0 {
+		max = defaultMaxSearchResults
+	}
+	if err = walkSearch(ctx, scoped, match, max, &result, nil); err != nil {
+		return
+	}
+	resp = result
+	return
+}
+
+// walkSearch appends the entries of the directory at the scoped path
+// matching to the result, recursively, up to max entries
+func walkSearch(ctx context.Context, scoped string, match func(name string) bool, max int, result *SearchResult, ancestors []os.FileInfo) (err error) {
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	d, stat, err := openFile(ctx, scoped)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	for _, ancestor := range ancestors {
+		if os.SameFile(ancestor, stat) {
+			return
+		}
+	}
+
+	files, err := readDir(d)
+	if err != nil {
+		err = fileError(err, scoped)
+		return
+	}
+	sort.Stable(ByName(files))
+
+	cfg := getConfig(ctx)
+	for _, item := range files {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if cfg.hiddenEntry(item) {
+			continue
+		}
+		itemPath := childPath(scoped, item.Name())
+		if match(item.Name()) {
+			if len(result.Entries) >= max {
+				result.Truncated = true
+				return
+			}
+			result.Entries = append(result.Entries, newStat(ctx, itemPath, item))
+		}
+		if item.IsDir() {
+			if err = walkSearch(ctx, itemPath, match, max, result, append(ancestors, stat)); err != nil || result.Truncated {
+				return
+			}
+		}
+	}
+	return
+}
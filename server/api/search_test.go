@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// searchRoot creates a tree to search
+func searchRoot(t *testing.T) string {
+	root := t.TempDir()
+	for _, dir := range []string{"docs", "docs/guides", "src"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	for _, file := range []string{"README.md", "docs/guide.md", "docs/guides/setup.md", "src/main.go", "src/guide.go"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	return root
+}
+
+// searchPaths returns the paths of the search result entries
+func searchPaths(t *testing.T, body map[string]interface{}) (paths []string) {
+	for _, entry := range entriesOf(t, body) {
+		paths = append(paths, entry.(map[string]interface{})["path"].(string))
+	}
+	return
+}
+
+func TestSearchEndpoint(t *testing.T) {
+
+	root := searchRoot(t)
+	tests := []struct {
+		target string
+		paths  []string
+	}{
+		{"/api/search/?q=guide", []string{"/docs/guide.md", "/docs/guides", "/src/guide.go"}},
+		{"/api/search/docs?q=guide", []string{"/docs/guide.md", "/docs/guides"}},
+		{"/api/search/?q=*.md&match=glob", []string{"/README.md", "/docs/guide.md", "/docs/guides/setup.md"}},
+		{"/api/search/?q=nothing", nil},
+	}
+
+	for _, test := range tests {
+		w := serveAPI(http.Dir(root), test.target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		paths := searchPaths(t, decodeBody(t, w))
+		if want, have := len(test.paths), len(paths); want != have {
+			t.Errorf("%s: expected paths %#v, got %#v", test.target, test.paths, paths)
+			continue
+		}
+		for i := range test.paths {
+			if want, have := test.paths[i], paths[i]; want != have {
+				t.Errorf("%s: expected path %#v, got %#v", test.target, want, have)
+			}
+		}
+	}
+}
+
+func TestSearchEndpoint_bounded(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/search/?q=.", nil)
+	w := serveRequest(http.Dir(searchRoot(t)), req, api.WithMaxSearchResults(2))
+	body := decodeBody(t, w)
+	if want, have := 2, len(entriesOf(t, body)); want != have {
+		t.Errorf("expected %d entries, got %d", want, have)
+	}
+	if want, have := true, body["truncated"]; want != have {
+		t.Errorf("expected truncated %#v, got %#v", want, have)
+	}
+}
+
+func TestSearchEndpoint_invalid(t *testing.T) {
+	root := searchRoot(t)
+	for _, target := range []string{
+		"/api/search/",
+		"/api/search/?q=[&match=glob",
+		"/api/search/?q=a&match=regexp",
+		"/api/search/README.md?q=a",
+	} {
+		w := serveAPI(http.Dir(root), target)
+		if want, have := http.StatusBadRequest, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+	}
+}
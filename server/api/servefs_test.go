@@ -0,0 +1,186 @@
+package api_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestServeFS(t *testing.T) {
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"hello.txt":         {Data: []byte("hello\n"), ModTime: modTime},
+		"folder/nested.txt": {Data: []byte("nested\n"), ModTime: modTime},
+	}
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.ServeFS("/api", fsys)(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	w := serve("/api/stats/hello.txt")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	body := decodeBody(t, w)
+	if want, have := float64(6), body["size"]; want != have {
+		t.Errorf("expected size %#v, got %#v", want, have)
+	}
+	if want, have := modTime.Format(http.TimeFormat), w.Header().Get("Last-Modified"); want != have {
+		t.Errorf("expected Last-Modified %#v, got %#v", want, have)
+	}
+
+	w = serve("/api/list/")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	list := decodeBody(t, w)
+	entries := entriesOf(t, list)
+	if want, have := 2, len(entries); want != have {
+		t.Fatalf("expected %d entries, got %d", want, have)
+	}
+	if entry := entryNamed(t, entries, "folder"); entry["type"] != "directory" {
+		t.Errorf("expected folder to be directory, got %#v", entry["type"])
+	}
+
+	w = serve("/api/read/folder/nested.txt")
+	if want, have := "nested\n", w.Body.String(); want != have {
+		t.Errorf("expected content %#v, got %#v", want, have)
+	}
+
+	w = serve("/api/stats/missing.txt")
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+// memInfo is the file info of a memFile, with no syscall stat
+type memInfo struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+func (info memInfo) Name() string       { return info.name }
+func (info memInfo) Size() int64        { return info.size }
+func (info memInfo) ModTime() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+func (info memInfo) IsDir() bool        { return info.isDir }
+func (info memInfo) Sys() interface{}   { return nil }
+func (info memInfo) Mode() os.FileMode {
+	if info.isDir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// memFile is an http.File in memory, neither an *os.File nor one of
+// an fs.FS
+type memFile struct {
+	*bytes.Reader
+	info    memInfo
+	entries []os.FileInfo
+}
+
+func (f *memFile) Close() error               { return nil }
+func (f *memFile) Stat() (os.FileInfo, error) { return f.info, nil }
+func (f *memFile) Readdir(count int) ([]os.FileInfo, error) {
+	if count <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+// memFileSystem serves files of the content by slash separated path,
+// their parent directories implied
+type memFileSystem map[string]string
+
+func (files memFileSystem) Open(name string) (http.File, error) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if content, ok := files[name]; ok {
+		return &memFile{
+			Reader: bytes.NewReader([]byte(content)),
+			info:   memInfo{name: path.Base(name), size: int64(len(content))},
+		}, nil
+	}
+	dir := &memFile{Reader: bytes.NewReader(nil), info: memInfo{name: path.Base(name), isDir: true}}
+	seen := make(map[string]bool)
+	for file, content := range files {
+		rest, ok := strings.CutPrefix(file, name+"/")
+		if name == "" {
+			rest, ok = file, true
+		}
+		if !ok {
+			continue
+		}
+		child, _, nested := strings.Cut(rest, "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		dir.entries = append(dir.entries, memInfo{name: child, size: int64(len(content)), isDir: nested})
+	}
+	if len(seen) == 0 && name != "" {
+		return nil, os.ErrNotExist
+	}
+	return dir, nil
+}
+
+func TestServeAPI_customFileSystem(t *testing.T) {
+
+	root := memFileSystem{
+		"hello.txt":         "hello\n",
+		"folder/nested.txt": "nested\n",
+	}
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/api/stats/hello.txt", http.StatusOK},
+		{"/api/stats/folder", http.StatusOK},
+		{"/api/stats/?recursive=true", http.StatusOK},
+		{"/api/stats/hello.txt?hash=sha256", http.StatusOK},
+		{"/api/list/folder", http.StatusOK},
+		{"/api/stats/missing.txt", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := serveAPI(root, test.target)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d: %s", test.target, want, have, w.Body.String())
+		}
+	}
+
+	body := decodeBody(t, serveAPI(root, "/api/stats/hello.txt"))
+	if want, have := float64(6), body["size"]; want != have {
+		t.Errorf("expected size %#v, got %#v", want, have)
+	}
+
+	// no access check of files not on the host
+	for _, key := range []string{"readable", "writable", "executable"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("expected no %s, got %#v", key, body[key])
+		}
+	}
+
+	body = decodeBody(t, serveAPI(root, "/api/stats/folder"))
+	if want, have := float64(1), body["entryCount"]; want != have {
+		t.Errorf("expected entryCount %#v, got %#v", want, have)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// errShutdown is the cause of the cancellation of the
+// requests in flight past the grace period of a shutdown
+var errShutdown = errors.New("api is shut down")
+
+// Shutdown coordinates the graceful shutdown of the expensive requests,
+// such as archives and trees, of the APIs configured with it. Call its
+// Shutdown method along with the one of the host http.Server.
+type Shutdown struct {
+	mu       sync.Mutex
+	closing  bool
+	inflight sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewShutdown returns a Shutdown coordinator accepting requests
+func NewShutdown() *Shutdown {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Shutdown{ctx: ctx, cancel: cancel}
+}
+
+// Shutdown stops accepting new expensive requests, which are then
+// service unavailable, and waits for those in flight to complete. If
+// ctx is done first, the requests in flight are canceled and the error
+// of ctx is returned.
+func (s *Shutdown) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// gate serves the request with h, tracked as in flight, unless shutting
+// down. h is served as is if s is nil.
+func (s *Shutdown) gate(cfg *Config, h http.HandlerFunc) http.HandlerFunc {
+	if s == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		if s.closing {
+			s.mu.Unlock()
+			cfg.setRetryAfter(w)
+			writeStatError(w, r, NewStatError(http.StatusServiceUnavailable, r.URL.Path))
+			return
+		}
+		s.inflight.Add(1)
+		s.mu.Unlock()
+		defer s.inflight.Done()
+
+		// canceled past the grace period of the shutdown
+		ctx, cancel := context.WithCancelCause(r.Context())
+		defer cancel(nil)
+		stop := context.AfterFunc(s.ctx, func() { cancel(errShutdown) })
+		defer stop()
+		h(w, r.WithContext(ctx))
+	}
+}
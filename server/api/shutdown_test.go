@@ -0,0 +1,89 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// slowFileSystem delays opening files, signaling the first open
+type slowFileSystem struct {
+	http.FileSystem
+	delay   time.Duration
+	entered chan struct{}
+}
+
+func (fs slowFileSystem) Open(name string) (http.File, error) {
+	select {
+	case fs.entered <- struct{}{}:
+	default:
+	}
+	time.Sleep(fs.delay)
+	return fs.FileSystem.Open(name)
+}
+
+func TestWithShutdown(t *testing.T) {
+
+	// archived in about 2 seconds, unless canceled
+	root := t.TempDir()
+	for i := 0; i < 200; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("file%03d.txt", i)), []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	fs := slowFileSystem{http.Dir(root), 10 * time.Millisecond, make(chan struct{}, 1)}
+	shutdown := api.NewShutdown()
+	handler := api.ServeAPI("/api", fs, api.WithShutdown(shutdown))(http.NotFoundHandler())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/archive/", nil))
+	}()
+	<-fs.entered
+
+	// canceled past the grace period
+	grace := 50 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	start := time.Now()
+	if err := shutdown.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected request to be canceled")
+	}
+	if elapsed := time.Since(start); elapsed > grace+500*time.Millisecond {
+		t.Errorf("expected request to terminate within the grace period, took %s", elapsed)
+	}
+
+	// new expensive requests are refused, others still served
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/tree/", nil))
+	if want, have := http.StatusServiceUnavailable, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/stats/file000.txt", nil))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestWithShutdown_idle(t *testing.T) {
+	shutdown := api.NewShutdown()
+	serveRequest(http.Dir(testRoot), httptest.NewRequest("GET", "/api/tree/", nil), api.WithShutdown(shutdown))
+	if err := shutdown.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// streamEntries is the number of directory entries above which a
+// listing is streamed instead of buffered, to keep memory bounded
+const streamEntries = 1000
+
+// ndjsonMediaType is the media type of listings streamed as
+// newline delimited JSON, one entry per line
+const ndjsonMediaType = "application/x-ndjson"
+
+// ndjsonFlushEntries is the number of NDJSON entries written between
+// flushes of the response
+const ndjsonFlushEntries = 100
+
+// largeListing reports if resp is a directory listing to be streamed
+func largeListing(resp interface{}) bool {
+	dir, ok := resp.(DirStat)
+	return ok && len(dir.Entries) > streamEntries
+}
+
+// streamListing writes the JSON of the directory listing to w one
+// entry at a time, without buffering the whole listing
+func streamListing(w io.Writer, dir DirStat) (err error) {
+	entries := dir.Entries
+	dir.Entries = nil
+	head, err := json.Marshal(dir)
+	if err != nil {
+		return
+	}
+	head = bytes.TrimSuffix(head, []byte("}"))
+	if _, err = w.Write(append(head, []byte(`,"entries":[`)...)); err != nil {
+		return
+	}
+	for i, entry := range entries {
+		if i > 0 {
+			if _, err = w.Write([]byte(",")); err != nil {
+				return
+			}
+		}
+		var b []byte
+		if b, err = json.Marshal(entry); err != nil {
+			return
+		}
+		if _, err = w.Write(b); err != nil {
+			return
+		}
+	}
+	_, err = w.Write([]byte("]}"))
+	return
+}
+
+// writeStream streams the directory listing as the response body
+// without Content-Length, compressed with encoding if given, and
+// wrapped in the success envelope if enveloped
+func writeStream(w http.ResponseWriter, r *http.Request, code int, dir DirStat, encoding string, encoders map[string]Encoder, enveloped bool) (err error) {
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+	cw := compressWriter(encoding, encoders, w)
+	suffix := "\n"
+	if enveloped {
+		if _, err = cw.Write([]byte(`{"status":"ok","data":`)); err != nil {
+			return
+		}
+		suffix = "}\n"
+	}
+	if err = streamListing(cw, dir); err != nil {
+		return
+	}
+	if _, err = cw.Write([]byte(suffix)); err != nil {
+		return
+	}
+	return cw.Close()
+}
+
+// writeNDJSON streams the entries of the directory listing as the
+// response body, one JSON object per line, flushing periodically
+func writeNDJSON(w http.ResponseWriter, r *http.Request, code int, dir DirStat) (err error) {
+	w.Header().Set("Content-Type", ndjsonMediaType)
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for i, entry := range dir.Entries {
+		if err = enc.Encode(entry); err != nil {
+			return
+		}
+		if (i+1)%ndjsonFlushEntries == 0 {
+			rc.Flush()
+		}
+	}
+	rc.Flush()
+	return
+}
@@ -0,0 +1,128 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestStatsEndpoint_contentLength(t *testing.T) {
+	w := serveAPI(http.Dir(testRoot), "/api/stats/hello.txt")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"); want != have {
+		t.Errorf("expected Content-Length %#v, got %#v", want, have)
+	}
+}
+
+func TestListEndpoint_streamed(t *testing.T) {
+
+	root := t.TempDir()
+	const count = 1200
+	for i := 0; i < count; i++ {
+		name := filepath.Join(root, fmt.Sprintf("file-%04d.txt", i))
+		if err := os.WriteFile(name, nil, 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	w := serveAPI(http.Dir(root), "/api/list/?limit=1100")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if have := w.Header().Get("Content-Length"); have != "" {
+		t.Errorf("expected no Content-Length on streamed listing, got %#v", have)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("error decoding response: %s", err)
+	}
+	if want, have := "directory", result["type"]; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+	if want, have := float64(count), result["total"]; want != have {
+		t.Errorf("expected total %#v, got %#v", want, have)
+	}
+	if want, have := 1100, len(entriesOf(t, result)); want != have {
+		t.Errorf("expected %d entries, got %d", want, have)
+	}
+}
+
+func TestListEndpoint_streamedEnvelope(t *testing.T) {
+
+	root := t.TempDir()
+	const count = 1200
+	for i := 0; i < count; i++ {
+		name := filepath.Join(root, fmt.Sprintf("file-%04d.txt", i))
+		if err := os.WriteFile(name, nil, 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/list/", nil)
+	body := decodeBody(t, serveRequest(http.Dir(root), req, api.WithEnvelope(true)))
+	if want, have := "ok", body["status"]; want != have {
+		t.Errorf("expected status %#v, got %#v", want, have)
+	}
+	data, ok := body["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected data object, got %#v", body["data"])
+	}
+	if want, have := count, len(entriesOf(t, data)); want != have {
+		t.Errorf("expected %d entries, got %d", want, have)
+	}
+}
+
+func TestListEndpoint_ndjson(t *testing.T) {
+
+	root := t.TempDir()
+	const count = 250
+	for i := 0; i < count; i++ {
+		name := filepath.Join(root, fmt.Sprintf("file-%04d.txt", i))
+		if err := os.WriteFile(name, nil, 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/list/?sort=name", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := serveRequest(http.Dir(root), req)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := "application/x-ndjson", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %d: error decoding %#v: %s", lines, scanner.Text(), err)
+		}
+		if want, have := fmt.Sprintf("file-%04d.txt", lines), entry["name"]; want != have {
+			t.Errorf("line %d: expected name %#v, got %#v", lines, want, have)
+		}
+		lines++
+	}
+	if want, have := count, lines; want != have {
+		t.Errorf("expected %d lines, got %d", want, have)
+	}
+
+	// JSON array by default
+	w = serveAPI(http.Dir(root), "/api/list/")
+	if want, have := "application/json", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected Content-Type %#v, got %#v", want, have)
+	}
+}
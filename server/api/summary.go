@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Summary is the aggregated statistics of the entries of a directory,
+// or of its whole tree if recursive
+type Summary struct {
+	Path      string       `json:"path"`
+	FileCount int          `json:"fileCount"`
+	DirCount  int          `json:"dirCount"`
+	TotalSize int64        `json:"totalSize"`
+	Largest   *SummaryFile `json:"largest,omitempty"`
+	Newest    *time.Time   `json:"newest,omitempty"`
+}
+
+// SummaryFile identifies a file of a summary
+type SummaryFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+func summaryEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
+
+	path, err := scopePath(req.(string))
+	if err != nil {
+		return
+	}
+	d, stat, err := openFile(ctx, path)
+	if err != nil {
+		return
+	}
+	d.Close()
+
+	// only directories can be summarized
+	if !stat.IsDir() {
+		err = NewStatError(http.StatusBadRequest, path)
+		return
+	}
+
+	summary := Summary{Path: rootPath(path)}
+	recursive := getEndpointContext(ctx).Query.Get("recursive") == "true"
+	err = walkEntries(ctx, path, recursive, nil, func(itemPath string, item os.FileInfo) {
+		if modTime := item.ModTime(); summary.Newest == nil || modTime.After(*summary.Newest) {
+			summary.Newest = &modTime
+		}
+		if item.IsDir() {
+			summary.DirCount++
+			return
+		}
+		summary.FileCount++
+		summary.TotalSize += item.Size()
+		if summary.Largest == nil || item.Size() > summary.Largest.Size {
+			summary.Largest = &SummaryFile{Path: rootPath(itemPath), Size: item.Size()}
+		}
+	})
+	if err != nil {
+		return
+	}
+
+	resp = summary
+	return
+}
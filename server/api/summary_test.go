@@ -0,0 +1,122 @@
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// summaryRoot creates a directory tree of known sizes and
+// modification times, the newest being the nested file
+func summaryRoot(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []struct {
+		name  string
+		size  int
+		mtime time.Time
+	}{
+		{"a.txt", 10, base},
+		{"b.txt", 300, base.Add(time.Hour)},
+		{"sub/c.txt", 20, base.Add(2 * time.Hour)},
+		{"sub/deep/d.txt", 500, base.Add(4 * time.Hour)},
+	}
+	for _, file := range files {
+		name := filepath.Join(root, filepath.FromSlash(file.name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.WriteFile(name, make([]byte, file.size), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.Chtimes(name, file.mtime, file.mtime); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	for _, dir := range []string{"sub/deep", "sub"} {
+		name := filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.Chtimes(name, base.Add(3*time.Hour), base.Add(3*time.Hour)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	return root
+}
+
+func TestSummaryEndpoint(t *testing.T) {
+
+	root := summaryRoot(t)
+	tests := []struct {
+		target    string
+		path      string
+		fileCount float64
+		dirCount  float64
+		totalSize float64
+		largest   string
+		newest    string
+	}{
+		{"/api/summary/", "/", 2, 1, 310, "/b.txt", "2020-01-01T03:00:00Z"},
+		{"/api/summary/?recursive=true", "/", 4, 2, 830, "/sub/deep/d.txt", "2020-01-01T04:00:00Z"},
+		{"/api/summary/sub", "/sub", 1, 1, 20, "/sub/c.txt", "2020-01-01T03:00:00Z"},
+		{"/api/summary/sub/deep?recursive=true", "/sub/deep", 1, 0, 500, "/sub/deep/d.txt", "2020-01-01T04:00:00Z"},
+	}
+
+	for _, test := range tests {
+		w := serveAPI(http.Dir(root), test.target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := test.path, body["path"]; want != have {
+			t.Errorf("%s: expected path %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.fileCount, body["fileCount"]; want != have {
+			t.Errorf("%s: expected fileCount %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.dirCount, body["dirCount"]; want != have {
+			t.Errorf("%s: expected dirCount %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.totalSize, body["totalSize"]; want != have {
+			t.Errorf("%s: expected totalSize %#v, got %#v", test.target, want, have)
+		}
+		largest, _ := body["largest"].(map[string]interface{})
+		if want, have := test.largest, largest["path"]; want != have {
+			t.Errorf("%s: expected largest %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.newest, body["newest"]; want != have {
+			t.Errorf("%s: expected newest %#v, got %#v", test.target, want, have)
+		}
+	}
+}
+
+func TestSummaryEndpoint_empty(t *testing.T) {
+	body := decodeBody(t, serveAPI(http.Dir(t.TempDir()), "/api/summary/"))
+	if want, have := float64(0), body["fileCount"]; want != have {
+		t.Errorf("expected fileCount %#v, got %#v", want, have)
+	}
+	for _, key := range []string{"largest", "newest"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("expected no %s of empty directory, got %#v", key, body[key])
+		}
+	}
+}
+
+func TestSummaryEndpoint_errors(t *testing.T) {
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/api/summary/hello.txt", http.StatusBadRequest},
+		{"/api/summary/missing", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := serveAPI(http.Dir(testRoot), test.target)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// hostPath returns the host path of the scoped path if the root
+// file system is an http.Dir. ok is false otherwise.
+func hostPath(ctx context.Context, scoped string) (name, root string, ok bool) {
+	dir, ok := getFilesystem(ctx).(http.Dir)
+	if !ok {
+		return
+	}
+	root = string(dir)
+	if root == "" {
+		root = "."
+	}
+	name = filepath.Join(root, filepath.FromSlash(path.Clean("/"+scoped)))
+	return
+}
+
+// rootRelative returns the slash separated path of the host path
+// relative to the host root. ok is false if it escapes the root.
+func rootRelative(root, name string) (rel string, ok bool) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return
+	}
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return
+	}
+	if rel, err = filepath.Rel(absRoot, absName); err != nil {
+		return
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	if rel == "." {
+		rel = ""
+	}
+	rel, ok = filepath.ToSlash(rel), true
+	return
+}
+
+// realPath returns the root relative path, with a leading slash, that
+// the scoped path resolves to if it is a symbolic link within an
+// http.Dir root. Returns empty string if the path is not a symbolic
+// link, cannot be resolved, or resolves outside of the root.
+func realPath(ctx context.Context, scoped string) string {
+	name, root, ok := hostPath(ctx, scoped)
+	if !ok {
+		return ""
+	}
+	if stat, err := os.Lstat(name); err != nil || stat.Mode()&os.ModeSymlink == 0 {
+		return ""
+	}
+	real, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return ""
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return ""
+	}
+	rel, within := rootRelative(realRoot, real)
+	if !within {
+		return ""
+	}
+	return rootPath(rel)
+}
+
+// linkStat returns the stats of the scoped path, without following it,
+// if it is a symbolic link within an http.Dir root. ok is false if the
+// path is not a symbolic link, or the root is not an http.Dir.
+func linkStat(ctx context.Context, scoped string) (stats interface{}, ok bool, err error) {
+
+	// hidden files are left for openFile to report
+	if getConfig(ctx).HideDotfiles && isHidden(scoped) {
+		return
+	}
+
+	name, root, isDir := hostPath(ctx, scoped)
+	if !isDir {
+		return
+	}
+	stat, lerr := os.Lstat(name)
+	if lerr != nil || stat.Mode()&os.ModeSymlink == 0 {
+		return
+	}
+	ok = true
+
+	// links of extensions not exposed are forbidden, as files
+	if getConfig(ctx).extensionDenied(scoped) {
+		err = NewStatError(http.StatusForbidden, scoped)
+		return
+	}
+
+	dest, err := os.Readlink(name)
+	if err != nil {
+		err = NewStatError(http.StatusInternalServerError, scoped)
+		return
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(name), dest)
+	}
+
+	// never reveal link destination outside of the root
+	target, within := rootRelative(root, dest)
+	if !within {
+		err = NewStatError(http.StatusForbidden, scoped)
+		return
+	}
+
+	fileStats := newStat(ctx, scoped, stat).(FileStat)
+	fileStats.Target = target
+	stats = fileStats
+	return
+}
@@ -0,0 +1,177 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// symlinkRoot creates a root with a symlink to a file within it,
+// and a symlink to a file outside of it
+func symlinkRoot(t *testing.T) string {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	if err := os.MkdirAll(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, name := range []string{filepath.Join(root, "dir", "target.txt"), filepath.Join(parent, "secret.txt")} {
+		if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := os.Symlink("dir/target.txt", filepath.Join(root, "inside")); err != nil {
+		t.Skipf("symlink not supported: %s", err)
+	}
+	if err := os.Symlink(filepath.Join(parent, "secret.txt"), filepath.Join(root, "outside")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return root
+}
+
+func TestWithNoFollowSymlinks(t *testing.T) {
+
+	root := symlinkRoot(t)
+	noFollow := api.WithNoFollowSymlinks(true)
+
+	// link within root
+	w := serveRequest(http.Dir(root), httptest.NewRequest("GET", "/api/stats/inside", nil), noFollow)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	body := decodeBody(t, w)
+	if want, have := true, body["isSymlink"]; want != have {
+		t.Errorf("expected isSymlink %#v, got %#v", want, have)
+	}
+	if want, have := "dir/target.txt", body["target"]; want != have {
+		t.Errorf("expected target %#v, got %#v", want, have)
+	}
+
+	// link outside of root
+	w = serveRequest(http.Dir(root), httptest.NewRequest("GET", "/api/stats/outside", nil), noFollow)
+	if want, have := http.StatusForbidden, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// regular file is not affected
+	w = serveRequest(http.Dir(root), httptest.NewRequest("GET", "/api/stats/dir/target.txt", nil), noFollow)
+	if _, ok := decodeBody(t, w)["isSymlink"]; ok {
+		t.Errorf("expected isSymlink to be omitted")
+	}
+}
+
+func TestWithNoFollowSymlinks_default(t *testing.T) {
+
+	// links are followed by default
+	body := decodeBody(t, serveAPI(http.Dir(symlinkRoot(t)), "/api/stats/inside"))
+	if _, ok := body["isSymlink"]; ok {
+		t.Errorf("expected isSymlink to be omitted")
+	}
+	if _, ok := body["target"]; ok {
+		t.Errorf("expected target to be omitted")
+	}
+	if want, have := float64(5), body["size"]; want != have {
+		t.Errorf("expected size %#v, got %#v", want, have)
+	}
+}
+
+func TestWithEntryErrors(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ok.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Symlink("missing.txt", filepath.Join(root, "broken")); err != nil {
+		t.Skipf("symlink not supported: %s", err)
+	}
+	if err := os.Symlink("loop", filepath.Join(root, "loop")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	codes := map[string]interface{}{
+		"broken": float64(http.StatusNotFound),
+		"loop":   float64(http.StatusLoopDetected),
+	}
+
+	// files without read access cannot be opened, unless by root
+	if runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		if err := os.WriteFile(filepath.Join(root, "denied.txt"), []byte("hello"), 0000); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		codes["denied.txt"] = float64(http.StatusForbidden)
+	}
+
+	req := httptest.NewRequest("GET", "/api/list/", nil)
+	w := serveRequest(http.Dir(root), req, api.WithEntryErrors(true))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	entries := entriesOf(t, decodeBody(t, w))
+	if want, have := len(codes)+1, len(entries); want != have {
+		t.Fatalf("expected %d entries, got %d", want, have)
+	}
+	for _, entry := range entries {
+		entry := entry.(map[string]interface{})
+		if entry["status"] != "error" {
+			if want, have := "ok.txt", entry["name"]; want != have {
+				t.Errorf("expected stats of %#v only, got %#v", want, have)
+			}
+			continue
+		}
+		name := filepath.Base(entry["path"].(string))
+		if want, have := codes[name], entry["code"]; want != have {
+			t.Errorf("%s: expected code %#v, got %#v", name, want, have)
+		}
+	}
+
+	// listed as is by default
+	for _, entry := range entriesOf(t, decodeBody(t, serveAPI(http.Dir(root), "/api/list/"))) {
+		if entry := entry.(map[string]interface{}); entry["status"] == "error" {
+			t.Errorf("expected no error entry by default, got %#v", entry)
+		}
+	}
+}
+
+func TestStatsEndpoint_realPath(t *testing.T) {
+
+	root := symlinkRoot(t)
+	if err := os.Symlink("dir", filepath.Join(root, "linkdir")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		target   string
+		realPath interface{}
+	}{
+		{"/api/stats/inside", "/dir/target.txt"},
+		{"/api/stats/linkdir", "/dir"},
+		{"/api/stats/outside", nil},
+		{"/api/stats/dir/target.txt", nil},
+	}
+	for _, test := range tests {
+		w := serveAPI(http.Dir(root), test.target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		if want, have := test.realPath, decodeBody(t, w)["realPath"]; want != have {
+			t.Errorf("%s: expected realPath %#v, got %#v", test.target, want, have)
+		}
+	}
+
+	// entries of listings, and links not followed
+	entries := entriesOf(t, decodeBody(t, serveAPI(http.Dir(root), "/api/list/")))
+	if want, have := "/dir/target.txt", entryNamed(t, entries, "inside")["realPath"]; want != have {
+		t.Errorf("expected realPath %#v of entry, got %#v", want, have)
+	}
+	if have, ok := entryNamed(t, entries, "outside")["realPath"]; ok {
+		t.Errorf("expected no realPath of entry outside of root, got %#v", have)
+	}
+	w := serveRequest(http.Dir(root), httptest.NewRequest("GET", "/api/stats/inside", nil), api.WithNoFollowSymlinks(true))
+	if want, have := "/dir/target.txt", decodeBody(t, w)["realPath"]; want != have {
+		t.Errorf("expected realPath %#v of link not followed, got %#v", want, have)
+	}
+}
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileTimes returns the status change time and birth time of the file
+func fileTimes(stat os.FileInfo) (ctime, birthtime time.Time) {
+	if sys, ok := stat.Sys().(*syscall.Stat_t); ok {
+		ctime = time.Unix(sys.Ctimespec.Unix())
+		birthtime = time.Unix(sys.Birthtimespec.Unix())
+	}
+	return
+}
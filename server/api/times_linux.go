@@ -0,0 +1,16 @@
+package api
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileTimes returns the status change time of the file. Birth time
+// is not provided by syscall.Stat_t on Linux.
+func fileTimes(stat os.FileInfo) (ctime, birthtime time.Time) {
+	if sys, ok := stat.Sys().(*syscall.Stat_t); ok {
+		ctime = time.Unix(sys.Ctim.Unix())
+	}
+	return
+}
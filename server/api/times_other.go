@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package api
+
+import (
+	"os"
+	"time"
+)
+
+// fileTimes is not supported on this platform
+func fileTimes(stat os.FileInfo) (ctime, birthtime time.Time) {
+	return
+}
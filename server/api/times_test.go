@@ -0,0 +1,42 @@
+//go:build linux || darwin
+
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStatsEndpoint_times(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fields := []string{"ctime"}
+	if runtime.GOOS == "darwin" {
+		fields = append(fields, "birthtime")
+	}
+
+	body := decodeBody(t, serveAPI(http.Dir(root), "/api/stats/file.txt"))
+	for _, field := range fields {
+		value, ok := body[field].(string)
+		if !ok {
+			t.Errorf("expected %s, got %#v", field, body[field])
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			t.Errorf("error parsing %s %#v: %s", field, value, err)
+			continue
+		}
+		if parsed.IsZero() || parsed.Unix() == 0 {
+			t.Errorf("expected non-zero %s, got %#v", field, value)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+func treeEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
+
+	path, err := scopePath(req.(string))
+	if err != nil {
+		return
+	}
+
+	// depth of recursion, negative for unlimited
+	depth := -1
+	if depthStr := getEndpointContext(ctx).Query.Get("depth"); depthStr != "" {
+		if depth, err = strconv.Atoi(depthStr); err != nil || depth < 0 {
+			err = NewStatError(http.StatusBadRequest, path)
+			return
+		}
+	}
+
+	// files modified since, if any
+	if _, err = querySince(getEndpointContext(ctx).Query); err != nil {
+		err = NewStatError(http.StatusBadRequest, path)
+		return
+	}
+
+	// files matching the glob, if any
+	if !validGlob(getEndpointContext(ctx).Query.Get("glob")) {
+		err = NewStatError(http.StatusBadRequest, path)
+		return
+	}
+
+	// the maximum depth bounds the recursion regardless of the
+	// client. directories at the maximum are flagged truncated.
+	truncate := false
+	if max := getConfig(ctx).maxDepth(); depth < 0 || depth > max {
+		depth, truncate = max, true
+	}
+
+	resp, err = walkTree(ctx, path, depth, truncate, nil)
+	return
+}
+
+// walkTree stats the path and, for directories, recursively
+// nests the stats of its entries up to the given depth. If truncate,
+// directories at the depth are marked truncated. ancestors are the
+// directories already visited in this branch. The glob query filters
+// files by name, directories are nested whatever their name.
+func walkTree(ctx context.Context, path string, depth int, truncate bool, ancestors []os.FileInfo) (node interface{}, err error) {
+
+	// abort if the client is gone or the request timed out
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	d, stat, err := openFile(ctx, path)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	node = newStat(ctx, path, stat)
+	if !stat.IsDir() {
+		return
+	}
+	if depth == 0 {
+		if dir, ok := node.(DirStat); ok && truncate {
+			dir.Truncated = true
+			node = dir
+		}
+		return
+	}
+
+	// file systems that follow symlinks may lead back to
+	// an ancestor directory. stop the recursion there.
+	for _, ancestor := range ancestors {
+		if os.SameFile(ancestor, stat) {
+			return
+		}
+	}
+
+	files, err := readDir(d)
+	if err != nil {
+		err = fileError(err, path)
+		return
+	}
+	if err = sortFiles(ctx, files); err != nil {
+		err = NewStatError(http.StatusBadRequest, path)
+		return
+	}
+
+	cfg := getConfig(ctx)
+	since, _ := querySince(getEndpointContext(ctx).Query)
+	glob := getEndpointContext(ctx).Query.Get("glob")
+	dir := node.(DirStat)
+	dir.Entries = make([]interface{}, 0, len(files))
+	for _, item := range files {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if cfg.hiddenEntry(item) || modifiedBefore(item, since) {
+			continue
+		}
+		itemPath := childPath(path, item.Name())
+
+		// symlinks are not followed, as listed by Readdir
+		if !item.IsDir() {
+			if !matchGlob(glob, item.Name()) {
+				continue
+			}
+			dir.Entries = append(dir.Entries, newStat(ctx, itemPath, item))
+			continue
+		}
+		var child interface{}
+		if child, err = walkTree(ctx, itemPath, depth-1, truncate, append(ancestors, stat)); err != nil {
+			return
+		}
+		dir.Entries = append(dir.Entries, child)
+	}
+
+	node = dir
+	return
+}
@@ -0,0 +1,204 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// entriesOf returns the entries of a decoded directory node
+func entriesOf(t *testing.T, node map[string]interface{}) []interface{} {
+	entries, ok := node["entries"].([]interface{})
+	if !ok {
+		t.Fatalf("expected %#v to have entries, got %#v", node["name"], node["entries"])
+	}
+	return entries
+}
+
+// entryNamed finds the entry of the given name
+func entryNamed(t *testing.T, entries []interface{}, name string) map[string]interface{} {
+	for _, entry := range entries {
+		if entry := entry.(map[string]interface{}); entry["name"] == name {
+			return entry
+		}
+	}
+	t.Fatalf("entry %#v not found", name)
+	return nil
+}
+
+func TestTreeEndpoint_depth(t *testing.T) {
+
+	// depth=0 stats the node only
+	body := decodeBody(t, serveAPI(http.Dir(testRoot), "/api/tree/?depth=0"))
+	if _, ok := body["entries"]; ok {
+		t.Errorf("depth=0: expected no entries, got %#v", body["entries"])
+	}
+
+	// depth=1 lists the directory without nesting
+	body = decodeBody(t, serveAPI(http.Dir(testRoot), "/api/tree/?depth=1"))
+	folder := entryNamed(t, entriesOf(t, body), "folder")
+	if _, ok := folder["entries"]; ok {
+		t.Errorf("depth=1: expected no entries in folder, got %#v", folder["entries"])
+	}
+
+	// depth=2 nests the entries of subdirectories
+	body = decodeBody(t, serveAPI(http.Dir(testRoot), "/api/tree/?depth=2"))
+	folder = entryNamed(t, entriesOf(t, body), "folder")
+	nested := entryNamed(t, entriesOf(t, folder), "nested.txt")
+	if want, have := "/folder/nested.txt", nested["path"]; want != have {
+		t.Errorf("depth=2: expected path %#v, got %#v", want, have)
+	}
+}
+
+func TestTreeEndpoint_invalidDepth(t *testing.T) {
+	for _, depth := range []string{"-1", "foo"} {
+		w := serveAPI(http.Dir(testRoot), "/api/tree/?depth="+depth)
+		if want, have := http.StatusBadRequest, w.Code; want != have {
+			t.Errorf("depth=%s: expected status %d, got %d", depth, want, have)
+		}
+	}
+}
+
+func TestTreeEndpoint_glob(t *testing.T) {
+
+	// files filtered by name, directories nested whatever theirs
+	body := decodeBody(t, serveAPI(http.Dir(testRoot), "/api/tree/?glob=nested*"))
+	entries := entriesOf(t, body)
+	if want, have := 1, len(entries); want != have {
+		t.Fatalf("expected %d entry, got %d", want, have)
+	}
+	folder := entryNamed(t, entries, "folder")
+	entryNamed(t, entriesOf(t, folder), "nested.txt")
+
+	w := serveAPI(http.Dir(testRoot), "/api/tree/?glob=%5B")
+	if want, have := http.StatusBadRequest, w.Code; want != have {
+		t.Errorf("expected status %d of malformed glob, got %d", want, have)
+	}
+}
+
+func TestTreeEndpoint_symlinkLoop(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "a"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Symlink("..", filepath.Join(root, "a", "loop")); err != nil {
+		t.Skipf("symlink not supported: %s", err)
+	}
+
+	w := serveAPI(http.Dir(root), "/api/tree/")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+
+	a := entryNamed(t, entriesOf(t, decodeBody(t, w)), "a")
+	loop := entryNamed(t, entriesOf(t, a), "loop")
+	if want, have := true, loop["isSymlink"]; want != have {
+		t.Errorf("expected isSymlink %#v, got %#v", want, have)
+	}
+	if _, ok := loop["entries"]; ok {
+		t.Errorf("expected symlink not to be followed")
+	}
+}
+
+// cancelFileSystem cancels the request after a number of opens
+type cancelFileSystem struct {
+	http.FileSystem
+	cancel func()
+	after  int
+	opened int
+}
+
+func (fs *cancelFileSystem) Open(name string) (http.File, error) {
+	fs.opened++
+	if fs.opened == fs.after {
+		fs.cancel()
+	}
+	return fs.FileSystem.Open(name)
+}
+
+func TestTreeEndpoint_cancelled(t *testing.T) {
+
+	root := t.TempDir()
+	for i := 0; i < 20; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%02d", i))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	for _, target := range []string{"/api/tree/", "/api/stats/?recursive=true"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		fs := &cancelFileSystem{FileSystem: http.Dir(root), cancel: cancel, after: 3}
+		w := serveRequest(fs, httptest.NewRequest("GET", target, nil).WithContext(ctx))
+		cancel()
+
+		if want, have := api.StatusClientClosedRequest, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", target, want, have)
+		}
+		if fs.opened > fs.after {
+			t.Errorf("%s: expected walk to stop after %d opens, got %d", target, fs.after, fs.opened)
+		}
+	}
+}
+
+func TestTreeEndpoint_maxDepth(t *testing.T) {
+
+	// a pathologically deep tree
+	root := t.TempDir()
+	dir := root
+	for i := 0; i < 100; i++ {
+		dir = filepath.Join(dir, "d")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// depth of the tree and truncation of its deepest directory
+	measure := func(node map[string]interface{}) (depth int, truncated interface{}) {
+		for {
+			entries, _ := node["entries"].([]interface{})
+			if len(entries) == 0 {
+				return depth, node["truncated"]
+			}
+			node = entries[0].(map[string]interface{})
+			depth++
+		}
+	}
+
+	tests := []struct {
+		target    string
+		opts      []api.Option
+		depth     int
+		truncated interface{}
+	}{
+		{"/api/tree/", nil, 64, true},
+		{"/api/tree/", []api.Option{api.WithMaxDepth(10)}, 10, true},
+		{"/api/tree/?depth=200", []api.Option{api.WithMaxDepth(10)}, 10, true},
+		{"/api/tree/?depth=5", []api.Option{api.WithMaxDepth(10)}, 5, nil},
+		{"/api/tree/", []api.Option{api.WithMaxDepth(200)}, 100, nil},
+	}
+	for _, test := range tests {
+		w := serveRequest(http.Dir(root), httptest.NewRequest("GET", test.target, nil), test.opts...)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		depth, truncated := measure(decodeBody(t, w))
+		if want, have := test.depth, depth; want != have {
+			t.Errorf("%s: expected depth %d, got %d", test.target, want, have)
+		}
+		if want, have := test.truncated, truncated; want != have {
+			t.Errorf("%s: expected truncated %#v, got %#v", test.target, want, have)
+		}
+	}
+}
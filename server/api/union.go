@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+
+	"github.com/go-midway/midway"
+)
+
+// ServeUnion generates a middleware to serve API for file / directory
+// information query of several roots overlaid, in order of precedence.
+// Each path resolves to the first root that has it, and directory
+// listings merge the entries of all roots, earlier roots taking
+// precedence on conflicting names. The union is never writable.
+func ServeUnion(path string, roots []http.FileSystem, opts ...Option) midway.Middleware {
+	return ServeAPI(path, unionFileSystem(roots), opts...)
+}
+
+// unionFileSystem overlays file systems in order of precedence
+type unionFileSystem []http.FileSystem
+
+// Open opens the file of the first root that has it. Errors other
+// than not found stop the lookup.
+func (roots unionFileSystem) Open(name string) (file http.File, err error) {
+	err = os.ErrNotExist
+	for i, root := range roots {
+		var openErr error
+		if file, openErr = root.Open(name); openErr != nil {
+			if !errors.Is(openErr, fs.ErrNotExist) {
+				return nil, openErr
+			}
+			continue
+		}
+		stat, statErr := file.Stat()
+		if statErr != nil {
+			file.Close()
+			return nil, statErr
+		}
+		if stat.IsDir() {
+			file = &unionDir{File: file, name: name, lower: roots[i+1:]}
+		}
+		return file, nil
+	}
+	return
+}
+
+// unionDir is a directory of a union, listing the entries of the
+// same directory in the lower roots after its own
+type unionDir struct {
+	http.File
+	name  string
+	lower unionFileSystem
+
+	// entries merged on first Readdir, not yet returned
+	entries []os.FileInfo
+	merged  bool
+}
+
+// Readdir reads the merged entries of the directory with the
+// semantics of os.File.Readdir
+func (dir *unionDir) Readdir(count int) (files []os.FileInfo, err error) {
+	if !dir.merged {
+		if dir.entries, err = dir.merge(); err != nil {
+			return
+		}
+		dir.merged = true
+	}
+	if count <= 0 {
+		files, dir.entries = dir.entries, nil
+		return
+	}
+	if len(dir.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(dir.entries) {
+		count = len(dir.entries)
+	}
+	files, dir.entries = dir.entries[:count], dir.entries[count:]
+	return
+}
+
+// merge lists the entries of the directory in all roots, skipping
+// the names already listed by an upper root
+func (dir *unionDir) merge() (entries []os.FileInfo, err error) {
+	if entries, err = readDir(dir.File); err != nil {
+		return
+	}
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		seen[entry.Name()] = true
+	}
+	for _, root := range dir.lower {
+		var lower []os.FileInfo
+		if lower, err = readLowerDir(root, dir.name); err != nil {
+			return
+		}
+		for _, entry := range lower {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return
+}
+
+// readLowerDir lists the directory of the root, if it has one
+func readLowerDir(root http.FileSystem, name string) (entries []os.FileInfo, err error) {
+	file, err := root.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	defer file.Close()
+	if stat, err := file.Stat(); err != nil || !stat.IsDir() {
+		return nil, err
+	}
+	return readDir(file)
+}
@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// unionRoots creates two roots with overlapping and distinct files
+func unionRoots(t *testing.T) []http.FileSystem {
+	t.Helper()
+
+	files := []map[string]string{
+		{"shared.txt": "upper\n", "upper.txt": "upper only\n", "dir/a.txt": "upper a\n"},
+		{"shared.txt": "lower shared\n", "lower.txt": "lower only\n", "dir/a.txt": "lower dir a\n", "dir/b.txt": "lower b\n"},
+	}
+	roots := make([]http.FileSystem, len(files))
+	for i, contents := range files {
+		root := t.TempDir()
+		for name, content := range contents {
+			name = filepath.Join(root, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+		roots[i] = http.Dir(root)
+	}
+	return roots
+}
+
+func TestServeUnion(t *testing.T) {
+
+	h := api.ServeUnion("/api", unionRoots(t))(http.NotFoundHandler())
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	// first root with the file wins
+	tests := []struct {
+		target string
+		size   float64
+	}{
+		{"/api/stats/shared.txt", 6},
+		{"/api/stats/upper.txt", 11},
+		{"/api/stats/lower.txt", 11},
+		{"/api/stats/dir/a.txt", 8},
+		{"/api/stats/dir/b.txt", 8},
+	}
+	for _, test := range tests {
+		w := serve(test.target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		if want, have := test.size, decodeBody(t, w)["size"]; want != have {
+			t.Errorf("%s: expected size %#v, got %#v", test.target, want, have)
+		}
+	}
+	if want, have := "upper\n", serve("/api/read/shared.txt").Body.String(); want != have {
+		t.Errorf("expected content %#v, got %#v", want, have)
+	}
+	if want, have := http.StatusNotFound, serve("/api/stats/missing.txt").Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// listings merged, without duplicates
+	listings := []struct {
+		target string
+		names  string
+	}{
+		{"/api/list/?sort=name", "dir,lower.txt,shared.txt,upper.txt"},
+		{"/api/list/dir?sort=name", "a.txt,b.txt"},
+	}
+	for _, test := range listings {
+		body := decodeBody(t, serve(test.target))
+		if want, have := test.names, strings.Join(entryNames(t, body), ","); want != have {
+			t.Errorf("%s: expected entries %#v, got %#v", test.target, want, have)
+		}
+	}
+
+	// entry of the upper root on conflict
+	body := decodeBody(t, serve("/api/list/"))
+	if want, have := float64(6), entryNamed(t, entriesOf(t, body), "shared.txt")["size"]; want != have {
+		t.Errorf("expected size %#v of upper shared.txt, got %#v", want, have)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"os"
+)
+
+// Usage is the aggregated disk usage of the files in a directory tree
+type Usage struct {
+	TotalSize int64 `json:"totalSize"`
+	FileCount int   `json:"fileCount"`
+}
+
+// diskUsage sums the sizes of all files contained in the directory
+// at path, recursively. Symlinks are counted as files, not followed.
+func diskUsage(ctx context.Context, path string) (usage Usage, err error) {
+	err = walkEntries(ctx, path, true, nil, func(_ string, item os.FileInfo) {
+		if !item.IsDir() {
+			usage.TotalSize += item.Size()
+			usage.FileCount++
+		}
+	})
+	return
+}
+
+// walkEntries calls visit for each entry of the directory at path,
+// then descends into its subdirectories if recursive. Hidden files
+// are skipped according to the config, symlinks are not followed.
+// ancestors are the directories already visited in this branch.
+func walkEntries(ctx context.Context, path string, recursive bool, ancestors []os.FileInfo, visit func(path string, item os.FileInfo)) (err error) {
+
+	// abort if the client is gone or the request timed out
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	d, stat, err := openFile(ctx, path)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	for _, ancestor := range ancestors {
+		if os.SameFile(ancestor, stat) {
+			return
+		}
+	}
+
+	files, err := readDir(d)
+	if err != nil {
+		err = fileError(err, path)
+		return
+	}
+
+	cfg := getConfig(ctx)
+	for _, item := range files {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if cfg.hiddenEntry(item) {
+			continue
+		}
+		itemPath := childPath(path, item.Name())
+		visit(itemPath, item)
+		if !recursive || !item.IsDir() {
+			continue
+		}
+		if err = walkEntries(ctx, itemPath, recursive, append(ancestors, stat), visit); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,112 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+func TestStatsEndpoint_recursive(t *testing.T) {
+
+	tests := []struct {
+		target    string
+		totalSize float64
+		fileCount float64
+	}{
+		{"/api/stats/?recursive=true", 13, 2},
+		{"/api/stats/folder?recursive=true", 7, 1},
+	}
+
+	for _, test := range tests {
+		w := serveAPI(http.Dir(testRoot), test.target)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := test.totalSize, body["totalSize"]; want != have {
+			t.Errorf("%s: expected totalSize %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.fileCount, body["fileCount"]; want != have {
+			t.Errorf("%s: expected fileCount %#v, got %#v", test.target, want, have)
+		}
+	}
+}
+
+func TestStatsEndpoint_recursiveOff(t *testing.T) {
+	body := decodeBody(t, serveAPI(http.Dir(testRoot), "/api/stats/folder"))
+	for _, key := range []string{"totalSize", "fileCount"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("expected no %s by default, got %#v", key, body[key])
+		}
+	}
+}
+
+func TestStatsEndpoint_entryCount(t *testing.T) {
+
+	root := t.TempDir()
+	for dir, count := range map[string]int{"small": 2, "large": 5} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for i := 0; i < count; i++ {
+			name := filepath.Join(root, dir, fmt.Sprintf("file%d.txt", i))
+			if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		target     string
+		entryCount float64
+		truncated  bool
+	}{
+		{"/api/stats/small", 2, false},
+		{"/api/stats/large", 3, true},
+		{"/api/stats/empty", 0, false},
+		{"/api/stats/large?count=true", 5, false},
+		{"/api/stats/small?count=true", 2, false},
+		{"/api/stats/hidden", 3, false},
+		{"/api/stats/hiddenLarge", 3, true},
+		{"/api/stats/hiddenLarge?count=true", 4, false},
+	}
+
+	// hidden entries near the bound, not counted in its place
+	for dir, count := range map[string]int{"hidden": 3, "hiddenLarge": 4} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for i := 0; i < count; i++ {
+			for _, prefix := range []string{"file", ".hidden"} {
+				name := filepath.Join(root, dir, fmt.Sprintf("%s%d.txt", prefix, i))
+				if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			}
+		}
+	}
+
+	for _, test := range tests {
+		w := serveRequest(http.Dir(root), httptest.NewRequest("GET", test.target, nil), api.WithEntryCountBound(3), api.WithHideDotfiles(true))
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := test.entryCount, body["entryCount"]; want != have {
+			t.Errorf("%s: expected entryCount %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.truncated, body["entryCountTruncated"] == true; want != have {
+			t.Errorf("%s: expected entryCountTruncated %t, got %#v", test.target, want, body["entryCountTruncated"])
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// writePath returns the host path of the scoped path for writing.
+// Writes must be enabled, the root must be an http.Dir, and the real
+// parent directory of the path must be within the root. Its extension
+// must be exposed, only denied ones checked if isDir, the path being or
+// to be a directory.
+func writePath(ctx context.Context, scoped string, isDir bool) (name string, err error) {
+
+	if !getConfig(ctx).Writable {
+		err = NewStatError(http.StatusForbidden, scoped)
+		return
+	}
+
+	// the root itself is never written
+	if scoped == "" {
+		err = NewStatError(http.StatusForbidden, scoped)
+		return
+	}
+
+	// hidden files are treated as not found
+	if getConfig(ctx).HideDotfiles && isHidden(scoped) {
+		err = NewStatError(http.StatusNotFound, scoped)
+		return
+	}
+
+	name, root, ok := hostPath(ctx, scoped)
+	if !ok {
+		err = NewStatError(http.StatusNotImplemented, scoped)
+		return
+	}
+
+	// nearest existing ancestor, resolving symlinks, must be within
+	// the root. missing directories below it are left to be created.
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		err = fileError(err, scoped)
+		return
+	}
+	dir, rest := filepath.Dir(name), filepath.Base(name)
+	realDir, err := filepath.EvalSymlinks(dir)
+	for errors.Is(err, os.ErrNotExist) && dir != filepath.Dir(dir) {
+		dir, rest = filepath.Dir(dir), filepath.Join(filepath.Base(dir), rest)
+		realDir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		err = fileError(err, scoped)
+		return
+	}
+	if _, within := rootRelative(realRoot, realDir); !within {
+		err = NewStatError(http.StatusForbidden, scoped)
+		return
+	}
+	name = filepath.Join(realDir, rest)
+
+	// paths of extensions not exposed are never written, checked before
+	// anything is, so that no rename or upload exposes their content
+	if stat, serr := os.Lstat(name); serr == nil && stat.IsDir() {
+		isDir = true
+	}
+	if getConfig(ctx).writeDenied(scoped, isDir) {
+		name, err = "", NewStatError(http.StatusForbidden, scoped)
+	}
+	return
+}
+
+// WritePlan is the write a dry-run request would perform,
+// having passed all the checks of the write
+type WritePlan struct {
+	DryRun    bool   `json:"dryRun"`
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	To        string `json:"to,omitempty"`
+	Recursive bool   `json:"recursive,omitempty"`
+	Exists    bool   `json:"exists"`
+}
+
+// isDryRun reports if the write request is only to be validated,
+// by the dryRun query
+func isDryRun(ctx context.Context) bool {
+	return getEndpointContext(ctx).Query.Get("dryRun") == "true"
+}
+
+// checkWriteAccess returns StatError of forbidden if the server
+// process may not write within the directory at dir, or its nearest
+// existing ancestor, where the platform supports access checks
+func checkWriteAccess(dir, scoped string) error {
+	_, err := os.Stat(dir)
+	for errors.Is(err, os.ErrNotExist) && dir != filepath.Dir(dir) {
+		dir = filepath.Dir(dir)
+		_, err = os.Stat(dir)
+	}
+	if err != nil {
+		return fileError(err, scoped)
+	}
+	if access := fileAccess(dir); access != nil && !(access.Writable && access.Executable) {
+		return NewStatError(http.StatusForbidden, scoped)
+	}
+	return nil
+}
+
+// moveRequest is the request of the move endpoint
+type moveRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// decodeMove decodes the from and to paths of a move request, from
+// the query or else a JSON object in the body
+func decodeMove(r *http.Request) (req interface{}, err error) {
+	move := moveRequest{
+		From: r.URL.Query().Get("from"),
+		To:   r.URL.Query().Get("to"),
+	}
+	if move.From == "" && move.To == "" {
+		if err = json.NewDecoder(r.Body).Decode(&move); err != nil {
+			err = bodyError(err, r.URL.Path)
+			return
+		}
+	}
+	if move.From == "" || move.To == "" {
+		err = NewStatError(http.StatusBadRequest, r.URL.Path)
+		return
+	}
+	req = move
+	return
+}
+
+// checkPreconditions returns StatError of precondition failed if the
+// If-Match or If-Unmodified-Since header of the request does not hold
+// for the current stats of the path, so that a write never overwrites
+// changes made since the client read its stats
+func checkPreconditions(ctx context.Context, path string) error {
+	header := getEndpointContext(ctx).Header
+	if header.Get("If-Match") == "" && header.Get("If-Unmodified-Since") == "" {
+		return nil
+	}
+	stats, err := statsEndpoint(ctx, path)
+	if serr, ok := err.(*StatError); ok && serr.Code == http.StatusNotFound {
+		stats, err = nil, nil
+	} else if err != nil {
+		return err
+	}
+	modTime, etag, exists := statValidators(stats)
+	if preconditionFailed(header, modTime, etag, exists) {
+		return NewStatError(http.StatusPreconditionFailed, path)
+	}
+	return nil
+}
+
+// moveEndpoint renames the from path to the to path, both within the
+// root, and returns the stats at the new path. Moving to an existing
+// path is a conflict. Moving across devices is not supported.
+func moveEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
+
+	move := req.(moveRequest)
+	from, err := scopePath(move.From)
+	if err != nil {
+		return
+	}
+	to, err := scopePath(move.To)
+	if err != nil {
+		return
+	}
+
+	fromName, err := writePath(ctx, from, false)
+	if err != nil {
+		return
+	}
+	fromStat, err := os.Lstat(fromName)
+	if err != nil {
+		err = fileError(err, from)
+		return
+	}
+	toName, err := writePath(ctx, to, fromStat.IsDir())
+	if err != nil {
+		return
+	}
+
+	if err = checkPreconditions(ctx, from); err != nil {
+		return
+	}
+	if _, lerr := os.Lstat(toName); lerr == nil {
+		err = NewStatError(http.StatusConflict, to)
+		return
+	}
+	if isDryRun(ctx) {
+		if err = checkWriteAccess(filepath.Dir(fromName), from); err != nil {
+			return
+		}
+		if _, serr := os.Stat(filepath.Dir(toName)); serr != nil {
+			err = fileError(serr, to)
+			return
+		}
+		if err = checkWriteAccess(filepath.Dir(toName), to); err != nil {
+			return
+		}
+		resp = WritePlan{DryRun: true, Operation: "move", Path: rootPath(from), To: rootPath(to), Exists: true}
+		return
+	}
+
+	if err = os.Rename(fromName, toName); err != nil {
+		if isCrossDevice(err) {
+			err = NewStatError(http.StatusUnprocessableEntity, to)
+			return
+		}
+		getConfig(ctx).logger().Error("error moving path", "from", from, "to", to, "error", err)
+		err = fileError(err, to)
+		return
+	}
+
+	return statsEndpoint(ctx, to)
+}
+
+// mkdirEndpoint creates the directory at the path, and any missing
+// parent, with the configured mode. Returns the stats of the directory.
+// A file existing at the path is a conflict.
+func mkdirEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
+
+	path, err := scopePath(req.(string))
+	if err != nil {
+		return
+	}
+	name, err := writePath(ctx, path, true)
+	if err != nil {
+		return
+	}
+
+	stat, serr := os.Stat(name)
+	if serr == nil && !stat.IsDir() {
+		err = NewStatError(http.StatusConflict, path)
+		return
+	}
+	if isDryRun(ctx) {
+		if serr != nil {
+			if err = checkWriteAccess(filepath.Dir(name), path); err != nil {
+				return
+			}
+		}
+		resp = WritePlan{DryRun: true, Operation: "mkdir", Path: rootPath(path), Exists: serr == nil}
+		return
+	}
+	if err = os.MkdirAll(name, getConfig(ctx).dirMode()); err != nil {
+		if errors.Is(err, syscall.ENOTDIR) {
+			err = NewStatError(http.StatusConflict, path)
+			return
+		}
+		getConfig(ctx).logger().Error("error creating directory", "path", path, "error", err)
+		err = fileError(err, path)
+		return
+	}
+
+	return statsEndpoint(ctx, path)
+}
+
+// deleteEndpoint removes the file or empty directory at the path, or
+// the whole directory tree with the recursive query. A non-empty
+// directory without recursive is a conflict.
+func deleteEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
+
+	path, err := scopePath(req.(string))
+	if err != nil {
+		return
+	}
+	name, err := writePath(ctx, path, false)
+	if err != nil {
+		return
+	}
+
+	stat, err := os.Lstat(name)
+	if err != nil {
+		err = fileError(err, path)
+		return
+	}
+	if err = checkPreconditions(ctx, path); err != nil {
+		return
+	}
+
+	remove, recursive := os.Remove, false
+	if stat.IsDir() {
+		if getEndpointContext(ctx).Query.Get("recursive") == "true" {
+			remove, recursive = os.RemoveAll, true
+		} else if empty, eerr := emptyDir(name); eerr != nil {
+			err = fileError(eerr, path)
+			return
+		} else if !empty {
+			err = NewStatError(http.StatusConflict, path)
+			return
+		}
+	}
+	if isDryRun(ctx) {
+		if err = checkWriteAccess(filepath.Dir(name), path); err != nil {
+			return
+		}
+		resp = WritePlan{DryRun: true, Operation: "delete", Path: rootPath(path), Recursive: recursive, Exists: true}
+		return
+	}
+	if err = remove(name); err != nil {
+		getConfig(ctx).logger().Error("error deleting path", "path", path, "error", err)
+		err = fileError(err, path)
+	}
+	return
+}
+
+// emptyDir reports if the directory at name has no entries
+func emptyDir(name string) (empty bool, err error) {
+	d, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	if _, err = d.Readdirnames(1); err == io.EOF {
+		return true, nil
+	}
+	return
+}
+
+// uploadRequest is the request of the upload endpoint
+type uploadRequest struct {
+	Path        string
+	Body        io.Reader
+	ContentType string
+}
+
+// decodeUpload decodes the path and body of an upload request
+func decodeUpload(r *http.Request) (req interface{}, err error) {
+	req = uploadRequest{
+		Path:        r.URL.Path,
+		Body:        r.Body,
+		ContentType: r.Header.Get("Content-Type"),
+	}
+	return
+}
+
+// uploadEndpoint writes the request body to the file at the path,
+// creating any missing parent directory, and returns its stats. The
+// content type of the request, if any, is reported in the stats. A
+// body larger than the configured maximum is rejected.
+func uploadEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
+
+	upload := req.(uploadRequest)
+	path, err := scopePath(upload.Path)
+	if err != nil {
+		return
+	}
+	name, err := writePath(ctx, path, false)
+	if err != nil {
+		return
+	}
+	cfg := getConfig(ctx)
+
+	stat, serr := os.Stat(name)
+	if serr == nil && stat.IsDir() {
+		err = NewStatError(http.StatusConflict, path)
+		return
+	}
+	if err = checkPreconditions(ctx, path); err != nil {
+		return
+	}
+	if isDryRun(ctx) {
+		if err = checkWriteAccess(filepath.Dir(name), path); err != nil {
+			return
+		}
+		resp = WritePlan{DryRun: true, Operation: "upload", Path: rootPath(path), Exists: serr == nil}
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(name), cfg.dirMode()); err != nil {
+		if errors.Is(err, syscall.ENOTDIR) {
+			err = NewStatError(http.StatusConflict, path)
+			return
+		}
+		err = fileError(err, path)
+		return
+	}
+
+	// write to a temporary file renamed in place when complete, so
+	// that a failed upload never leaves a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		err = fileError(err, path)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// the body is limited to the configured maximum when served
+	if _, err = io.Copy(tmp, upload.Body); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			err = NewStatError(http.StatusRequestEntityTooLarge, path)
+			return
+		}
+		cfg.logger().Error("error uploading file", "path", path, "error", err)
+		err = NewStatError(http.StatusInternalServerError, path)
+		return
+	}
+	if err = tmp.Chmod(0644); err != nil {
+		err = fileError(err, path)
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		err = fileError(err, path)
+		return
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		cfg.logger().Error("error uploading file", "path", path, "error", err)
+		err = fileError(err, path)
+		return
+	}
+
+	if resp, err = statsEndpoint(ctx, path); err != nil {
+		return
+	}
+	if fileStats, ok := resp.(FileStat); ok && upload.ContentType != "" {
+		fileStats.ContentType = upload.ContentType
+		resp = fileStats
+	}
+	return
+}
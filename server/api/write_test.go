@@ -0,0 +1,430 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/api"
+)
+
+// writableRoot creates a root with a file and a directory
+func writableRoot(t *testing.T) string {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return root
+}
+
+func TestMoveEndpoint(t *testing.T) {
+
+	root := writableRoot(t)
+	req := httptest.NewRequest("POST", "/api/move", strings.NewReader(`{"from": "file.txt", "to": "dir/moved.txt"}`))
+	w := serveRequest(http.Dir(root), req, api.WithWritable(true))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d: %s", want, have, w.Body.String())
+	}
+	body := decodeBody(t, w)
+	if want, have := "/dir/moved.txt", body["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dir", "moved.txt")); err != nil {
+		t.Errorf("expected moved file: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "file.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected source to be gone, got %v", err)
+	}
+}
+
+func TestMoveEndpoint_errors(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		target   string
+		writable bool
+		code     int
+	}{
+		{"conflict", "/api/move?from=file.txt&to=dir", true, http.StatusConflict},
+		{"traversal from", "/api/move?from=../secret&to=dir/secret", true, http.StatusForbidden},
+		{"traversal to", "/api/move?from=file.txt&to=../file.txt", true, http.StatusForbidden},
+		{"missing source", "/api/move?from=missing.txt&to=dir/missing.txt", true, http.StatusNotFound},
+		{"missing parent", "/api/move?from=file.txt&to=missing/file.txt", true, http.StatusNotFound},
+		{"missing to", "/api/move?from=file.txt", true, http.StatusBadRequest},
+		{"read only", "/api/move?from=file.txt&to=dir/file.txt", false, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		root := writableRoot(t)
+		req := httptest.NewRequest("POST", test.target, nil)
+		w := serveRequest(http.Dir(root), req, api.WithWritable(test.writable))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+		}
+		if _, err := os.Stat(filepath.Join(root, "file.txt")); err != nil {
+			t.Errorf("%s: expected source to remain: %s", test.name, err)
+		}
+	}
+}
+
+func TestMoveEndpoint_symlinkEscape(t *testing.T) {
+
+	root := writableRoot(t)
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/move?from=file.txt&to=escape/file.txt", nil)
+	w := serveRequest(http.Dir(root), req, api.WithWritable(true))
+	if want, have := http.StatusForbidden, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "file.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected no file outside of root, got %v", err)
+	}
+}
+
+func TestMkdirEndpoint(t *testing.T) {
+
+	root := writableRoot(t)
+	req := httptest.NewRequest("POST", "/api/mkdir/dir/nested/deep", nil)
+	w := serveRequest(http.Dir(root), req, api.WithWritable(true), api.WithDirMode(0700))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d: %s", want, have, w.Body.String())
+	}
+	body := decodeBody(t, w)
+	if want, have := "directory", body["type"]; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+	if want, have := "/dir/nested/deep", body["path"]; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+	stat, err := os.Stat(filepath.Join(root, "dir", "nested", "deep"))
+	if err != nil {
+		t.Fatalf("expected created directory: %s", err)
+	}
+	if want, have := os.FileMode(0700), stat.Mode().Perm(); want != have {
+		t.Errorf("expected mode %s, got %s", want, have)
+	}
+}
+
+func TestMkdirEndpoint_errors(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		target string
+		method string
+		code   int
+	}{
+		{"file exists", "/api/mkdir/file.txt", "POST", http.StatusConflict},
+		{"file parent", "/api/mkdir/file.txt/nested", "POST", http.StatusConflict},
+		{"traversal", "/api/mkdir/../outside", "POST", http.StatusForbidden},
+		{"method", "/api/mkdir/new", "GET", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		root := writableRoot(t)
+		req := httptest.NewRequest(test.method, test.target, nil)
+		w := serveRequest(http.Dir(root), req, api.WithWritable(true))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+		}
+	}
+}
+
+func TestDeleteEndpoint(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		setup  func(root string)
+		target string
+		code   int
+		gone   string
+	}{
+		{"file", nil, "/api/delete/file.txt", http.StatusNoContent, "file.txt"},
+		{"empty directory", nil, "/api/delete/dir", http.StatusNoContent, "dir"},
+		{"non-empty directory", func(root string) {
+			os.WriteFile(filepath.Join(root, "dir", "nested.txt"), nil, 0644)
+		}, "/api/delete/dir", http.StatusConflict, ""},
+		{"recursive", func(root string) {
+			os.WriteFile(filepath.Join(root, "dir", "nested.txt"), nil, 0644)
+		}, "/api/delete/dir?recursive=true", http.StatusNoContent, "dir"},
+		{"missing", nil, "/api/delete/missing.txt", http.StatusNotFound, ""},
+		{"root", nil, "/api/delete/", http.StatusForbidden, ""},
+		{"traversal", nil, "/api/delete/../outside", http.StatusForbidden, ""},
+	}
+
+	for _, test := range tests {
+		root := writableRoot(t)
+		if test.setup != nil {
+			test.setup(root)
+		}
+		req := httptest.NewRequest("DELETE", test.target, nil)
+		w := serveRequest(http.Dir(root), req, api.WithWritable(true))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+		}
+		if test.gone != "" {
+			if _, err := os.Stat(filepath.Join(root, test.gone)); !os.IsNotExist(err) {
+				t.Errorf("%s: expected %s to be deleted, got %v", test.name, test.gone, err)
+			}
+		}
+		if _, err := os.Stat(root); err != nil {
+			t.Errorf("%s: expected root to remain: %s", test.name, err)
+		}
+	}
+}
+
+func TestDeleteEndpoint_readOnly(t *testing.T) {
+	root := writableRoot(t)
+	w := serveRequest(http.Dir(root), httptest.NewRequest("DELETE", "/api/delete/file.txt", nil))
+	if want, have := http.StatusForbidden, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if _, err := os.Stat(filepath.Join(root, "file.txt")); err != nil {
+		t.Errorf("expected file to remain: %s", err)
+	}
+}
+
+func TestUploadEndpoint(t *testing.T) {
+
+	root := writableRoot(t)
+	req := httptest.NewRequest("PUT", "/api/upload/new/nested/upload.dat", strings.NewReader("uploaded content"))
+	req.Header.Set("Content-Type", "application/x-custom")
+	w := serveRequest(http.Dir(root), req, api.WithWritable(true), api.WithMaxUploadSize(1024))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d: %s", want, have, w.Body.String())
+	}
+	body := decodeBody(t, w)
+	if want, have := float64(len("uploaded content")), body["size"]; want != have {
+		t.Errorf("expected size %#v, got %#v", want, have)
+	}
+	if want, have := "application/x-custom", body["contentType"]; want != have {
+		t.Errorf("expected contentType %#v, got %#v", want, have)
+	}
+	content, err := os.ReadFile(filepath.Join(root, "new", "nested", "upload.dat"))
+	if err != nil {
+		t.Fatalf("expected uploaded file: %s", err)
+	}
+	if want, have := "uploaded content", string(content); want != have {
+		t.Errorf("expected content %#v, got %#v", want, have)
+	}
+}
+
+func TestUploadEndpoint_errors(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		code   int
+	}{
+		{"too large", "/api/upload/large.txt", strings.Repeat("x", 11), http.StatusRequestEntityTooLarge},
+		{"directory", "/api/upload/dir", "hello", http.StatusConflict},
+		{"traversal", "/api/upload/../outside.txt", "hello", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		root := writableRoot(t)
+		req := httptest.NewRequest("PUT", test.target, strings.NewReader(test.body))
+		w := serveRequest(http.Dir(root), req, api.WithWritable(true), api.WithMaxUploadSize(10))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+		}
+		entries, _ := os.ReadDir(root)
+		if want, have := 2, len(entries); want != have {
+			t.Errorf("%s: expected root untouched, got %d entries", test.name, have)
+		}
+	}
+}
+
+func TestWriteEndpoints_preconditions(t *testing.T) {
+
+	root := writableRoot(t)
+	name := filepath.Join(root, "file.txt")
+	etag := serveAPI(http.Dir(root), "/api/stats/file.txt").Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header")
+	}
+
+	// the file changes after the client read its stats
+	if err := os.WriteFile(name, []byte("changed"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	modTime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	stale := modTime.Add(-time.Minute).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		header string
+		value  string
+	}{
+		{"upload If-Match", "PUT", "/api/upload/file.txt", "If-Match", etag},
+		{"upload If-Unmodified-Since", "PUT", "/api/upload/file.txt", "If-Unmodified-Since", stale},
+		{"move If-Match", "POST", "/api/move?from=file.txt&to=moved.txt", "If-Match", etag},
+		{"delete If-Unmodified-Since", "DELETE", "/api/delete/file.txt", "If-Unmodified-Since", stale},
+		{"upload If-Match missing", "PUT", "/api/upload/missing.txt", "If-Match", "*"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader("overwritten"))
+		req.Header.Set(test.header, test.value)
+		w := serveRequest(http.Dir(root), req, api.WithWritable(true))
+		if want, have := http.StatusPreconditionFailed, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+		}
+	}
+	if content, err := os.ReadFile(name); err != nil || string(content) != "changed" {
+		t.Errorf("expected file to remain unchanged, got %#v (%v)", string(content), err)
+	}
+
+	// with the current entity tag, the write proceeds
+	current := serveAPI(http.Dir(root), "/api/stats/file.txt").Header().Get("ETag")
+	req := httptest.NewRequest("PUT", "/api/upload/file.txt", strings.NewReader("overwritten"))
+	req.Header.Set("If-Match", current)
+	w := serveRequest(http.Dir(root), req, api.WithWritable(true))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d: %s", want, have, w.Body.String())
+	}
+}
+
+func TestWriteEndpoints_dryRun(t *testing.T) {
+
+	root := writableRoot(t)
+	if err := os.WriteFile(filepath.Join(root, "dir", "nested.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		method    string
+		target    string
+		code      int
+		operation string
+	}{
+		{"DELETE", "/api/delete/file.txt?dryRun=true", http.StatusOK, "delete"},
+		{"DELETE", "/api/delete/dir?dryRun=true&recursive=true", http.StatusOK, "delete"},
+		{"DELETE", "/api/delete/dir?dryRun=true", http.StatusConflict, ""},
+		{"DELETE", "/api/delete/missing.txt?dryRun=true", http.StatusNotFound, ""},
+		{"POST", "/api/move?from=file.txt&to=dir/moved.txt&dryRun=true", http.StatusOK, "move"},
+		{"POST", "/api/move?from=file.txt&to=missing/moved.txt&dryRun=true", http.StatusNotFound, ""},
+		{"POST", "/api/mkdir/new/sub?dryRun=true", http.StatusOK, "mkdir"},
+		{"PUT", "/api/upload/new.txt?dryRun=true", http.StatusOK, "upload"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader("content"))
+		w := serveRequest(http.Dir(root), req, api.WithWritable(true))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.target, want, have)
+			continue
+		}
+		if test.code != http.StatusOK {
+			continue
+		}
+		body := decodeBody(t, w)
+		if want, have := true, body["dryRun"]; want != have {
+			t.Errorf("%s: expected dryRun %#v, got %#v", test.target, want, have)
+		}
+		if want, have := test.operation, body["operation"]; want != have {
+			t.Errorf("%s: expected operation %#v, got %#v", test.target, want, have)
+		}
+	}
+
+	// nothing written
+	for _, name := range []string{"file.txt", "dir/nested.txt"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Errorf("expected %s to remain: %s", name, err)
+		}
+	}
+	for _, name := range []string{"dir/moved.txt", "new", "new.txt"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("expected no %s, got %v", name, err)
+		}
+	}
+
+	// write permission of the parent directory is checked
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		return
+	}
+	if err := os.Chmod(filepath.Join(root, "dir"), 0555); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.Chmod(filepath.Join(root, "dir"), 0755)
+	req := httptest.NewRequest("DELETE", "/api/delete/dir/nested.txt?dryRun=true", nil)
+	w := serveRequest(http.Dir(root), req, api.WithWritable(true))
+	if want, have := http.StatusForbidden, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestWriteEndpoints_extensions(t *testing.T) {
+
+	root := writableRoot(t)
+	if err := os.WriteFile(filepath.Join(root, "secret.key"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	opts := []api.Option{api.WithWritable(true), api.WithDeniedExtensions(".key")}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{"move from denied", "POST", "/api/move?from=secret.key&to=secret.txt", ""},
+		{"move to denied", "POST", "/api/move?from=file.txt&to=file.key", ""},
+		{"upload denied", "PUT", "/api/upload/new/upload.key", "uploaded"},
+		{"mkdir denied", "POST", "/api/mkdir/dir.key", ""},
+		{"delete denied", "DELETE", "/api/delete/secret.key", ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		w := serveRequest(http.Dir(root), req, opts...)
+		if want, have := http.StatusForbidden, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.name, want, have)
+		}
+	}
+
+	// nothing written, so that the denied content is never served
+	for _, name := range []string{"secret.txt", "file.key", "new", "dir.key"} {
+		if _, err := os.Lstat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("expected no %s written, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"secret.key", "file.txt"} {
+		if _, err := os.Lstat(filepath.Join(root, name)); err != nil {
+			t.Errorf("expected %s kept, got %s", name, err)
+		}
+	}
+	w := serveRequest(http.Dir(root), httptest.NewRequest("GET", "/api/read/secret.txt", nil), opts...)
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Errorf("expected status %d reading renamed secret, got %d", want, have)
+	}
+
+	// directories are only subject to the denied extensions
+	req := httptest.NewRequest("POST", "/api/mkdir/docs", nil)
+	w = serveRequest(http.Dir(root), req, api.WithWritable(true), api.WithAllowedExtensions(".txt"))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d creating directory, got %d", want, have)
+	}
+	req = httptest.NewRequest("PUT", "/api/upload/docs/upload.dat", strings.NewReader("uploaded"))
+	w = serveRequest(http.Dir(root), req, api.WithWritable(true), api.WithAllowedExtensions(".txt"))
+	if want, have := http.StatusForbidden, w.Code; want != have {
+		t.Errorf("expected status %d uploading not allowed, got %d", want, have)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "docs", "upload.dat")); !os.IsNotExist(err) {
+		t.Errorf("expected no upload written, got %v", err)
+	}
+}